@@ -51,6 +51,38 @@ var analyzeCmd = &cobra.Command{
 			yellow.Println("No patterns detected yet. Track more commands!")
 		}
 
+		// Show programs, counting every segment of pipelines and lists
+		bold.Print("\n=== Most Used Programs ===\n")
+		programs, err := db.GetProgramUsage(10)
+		if err != nil {
+			return fmt.Errorf("failed to get program usage: %w", err)
+		}
+
+		if len(programs) > 0 {
+			for _, p := range programs {
+				cyan.Printf("  %-20s", p.Base)
+				dim.Printf(" %d runs\n", p.RunCount)
+			}
+		} else {
+			dim.Println("No programs recorded yet.")
+		}
+
+		// Show subcommand paths, e.g. "kubectl get pods" or "git remote add"
+		bold.Print("\n=== Most Used Subcommands ===\n")
+		subcommands, err := db.GetSubcommandUsage(10)
+		if err != nil {
+			return fmt.Errorf("failed to get subcommand usage: %w", err)
+		}
+
+		if len(subcommands) > 0 {
+			for _, s := range subcommands {
+				cyan.Printf("  %-30s", s.Base+" "+s.Subcommand)
+				dim.Printf(" %d runs\n", s.RunCount)
+			}
+		} else {
+			dim.Println("No subcommands recorded yet.")
+		}
+
 		// Show failure stats
 		bold.Print("\n=== Failure Analysis ===\n")
 		failures, err := db.GetFailureStats()
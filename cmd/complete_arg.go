@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
+	"github.com/kaustuvbot/kwik-cmd/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var (
+	completeArgLine   string
+	completeArgCursor int
+	completeArgLimit  int
+)
+
+var completeArgCmd = &cobra.Command{
+	Use:   "complete-arg --line \"<buffer>\" [--cursor N]",
+	Short: "Complete a flag value or argument from the values used before",
+	Long: `Print the values used before in the slot the cursor is in, one per line,
+best first: the namespaces after "kubectl -n ", the branches after "git
+checkout ", the hosts after "ssh ". Values are learned from the commands
+tracked, per command, subcommand and flag or argument position, and ranked
+by how recently and often they were used and where. Only values starting
+with what has been typed at the cursor are printed. The cursor is a byte
+offset into the line and defaults to its end.
+Examples:
+  kwik-cmd complete-arg --line "kubectl -n "
+  kwik-cmd complete-arg --line "git checkout ma" --cursor 15`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		slot, ok := parser.CompletionSlot(completeArgLine, completeArgCursor)
+		if !ok {
+			return nil
+		}
+
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		currentDir, _ := os.Getwd()
+		values, err := db.CompleteArg(db.ArgQuery{
+			Base:       slot.Base,
+			Subcommand: slot.Subcommand,
+			Flags:      slot.Aliases,
+			Position:   slot.Position,
+			Prefix:     slot.Prefix,
+			Directory:  currentDir,
+			Project:    project.Detect(currentDir).ID(),
+			Limit:      completeArgLimit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to complete argument: %w", err)
+		}
+		for _, v := range values {
+			fmt.Println(v.Value)
+		}
+		return nil
+	},
+}
+
+func init() {
+	completeArgCmd.Flags().StringVar(&completeArgLine, "line", "", "The command line being typed")
+	completeArgCmd.Flags().IntVar(&completeArgCursor, "cursor", -1, "Byte offset of the cursor in the line (default: end of line)")
+	completeArgCmd.Flags().IntVarP(&completeArgLimit, "limit", "n", 20, "Maximum number of values")
+	completeArgCmd.MarkFlagRequired("line")
+	rootCmd.AddCommand(completeArgCmd)
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configJSONFlag bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and change kwik-cmd settings",
+	Long: `View and change the settings in the config file (~/.kwik-cmd/config.yaml or
+$KWIK_CMD_CONFIG). Values are type-checked and validated before they are saved.
+Examples:
+  kwik-cmd config list
+  kwik-cmd config get max_suggestions
+  kwik-cmd config set max_suggestions 5
+  kwik-cmd config set recency_weight=0.5 frequency_weight=0.3
+  kwik-cmd config unset recency_weight frequency_weight
+  kwik-cmd config validate --json`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, err := config.LookupKey(args[0])
+		if err != nil {
+			return err
+		}
+		if configJSONFlag {
+			return printJSON(map[string]interface{}{k.Name: k.Value()})
+		}
+		fmt.Println(config.FormatValue(k.Value()))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value> | set <key>=<value>...",
+	Short: "Change one or more settings",
+	Long: `Change one or more settings. Several keys can be set at once with
+key=value pairs; they are validated together, so related values such as the
+ranking weights can be changed in one step.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values := map[string]string{}
+		if len(args) == 2 && !strings.Contains(args[0], "=") {
+			values[args[0]] = args[1]
+		} else {
+			for _, arg := range args {
+				name, value, ok := strings.Cut(arg, "=")
+				if !ok {
+					return fmt.Errorf("expected <key>=<value>, got %q", arg)
+				}
+				values[name] = value
+			}
+		}
+
+		if err := config.Set(values); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			green.Print("✓ ")
+			fmt.Printf("%s = %s\n", name, values[name])
+		}
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>...",
+	Short: "Restore settings to their defaults",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Unset(args...); err != nil {
+			return err
+		}
+		for _, name := range args {
+			k, _ := config.LookupKey(name)
+			green.Print("✓ ")
+			fmt.Printf("%s = %s (default)\n", name, config.FormatValue(k.Default()))
+		}
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys := config.Keys()
+
+		if configJSONFlag {
+			type entry struct {
+				Key         string      `json:"key"`
+				Value       interface{} `json:"value"`
+				Default     interface{} `json:"default"`
+				Type        string      `json:"type"`
+				Description string      `json:"description"`
+			}
+			entries := make([]entry, 0, len(keys))
+			for _, k := range keys {
+				entries = append(entries, entry{k.Name, k.Value(), k.Default(), k.Type, k.Description})
+			}
+			return printJSON(entries)
+		}
+
+		dim.Printf("# %s\n", config.File())
+		for _, k := range keys {
+			cyan.Printf("%-24s", k.Name)
+			value, def := config.FormatValue(k.Value()), config.FormatValue(k.Default())
+			fmt.Printf(" = %s", value)
+			if value != def {
+				dim.Printf("  (default %s)", def)
+			}
+			fmt.Println()
+			dim.Printf("  %s [%s]\n", k.Description, k.Type)
+		}
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $EDITOR and validate it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+		}
+
+		parts := strings.Fields(editor)
+		editCmd := exec.Command(parts[0], append(parts[1:], config.File())...)
+		editCmd.Stdin = os.Stdin
+		editCmd.Stdout = os.Stdout
+		editCmd.Stderr = os.Stderr
+		if err := editCmd.Run(); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+
+		return reportValidation(config.ValidateFile())
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown keys and invalid values",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		errs := config.ValidateFile()
+
+		if configJSONFlag {
+			messages := make([]string, 0, len(errs))
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			if err := printJSON(map[string]interface{}{
+				"file":   config.File(),
+				"valid":  len(errs) == 0,
+				"errors": messages,
+			}); err != nil {
+				return err
+			}
+			if len(errs) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		}
+
+		return reportValidation(errs)
+	},
+}
+
+var configDirCmd = &cobra.Command{
+	Use:   "dir",
+	Short: "Print the data directory (used by the shell hooks)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := config.Dir()
+		if err != nil {
+			return err
+		}
+		fmt.Println(dir)
+		return nil
+	},
+}
+
+// reportValidation prints validation problems and turns them into an error
+func reportValidation(errs []error) error {
+	if len(errs) == 0 {
+		green.Printf("✓ %s is valid\n", config.File())
+		return nil
+	}
+	for _, err := range errs {
+		yellow.Printf("  - %v\n", err)
+	}
+	return fmt.Errorf("%s has %d problem(s)", config.File(), len(errs))
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func init() {
+	configCmd.PersistentFlags().BoolVar(&configJSONFlag, "json", false, "Output JSON")
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configDirCmd)
+	for _, c := range configCmd.Commands() {
+		// Validation errors are user errors; don't bury them under usage text
+		c.SilenceUsage = true
+	}
+	rootCmd.AddCommand(configCmd)
+}
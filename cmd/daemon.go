@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the tracking daemon in the foreground",
+	Long: `Run a long-lived kwik-cmd process that keeps the database open and serves
+track, suggest and search requests over a Unix socket next to the database.
+While it is running, 'track', 'suggest --plain' and 'search --plain' go
+through it instead of opening the database themselves.
+Examples:
+  kwik-cmd daemon &
+  kwik-cmd daemon status
+  kwik-cmd daemon stop`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := daemon.Listen()
+		if err != nil {
+			return err
+		}
+		green.Print("✓ kwik-cmd daemon listening on ")
+		fmt.Println(server.Path())
+		return server.Serve()
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := daemon.Call(daemon.Request{Op: daemon.OpPing})
+		if err != nil {
+			yellow.Printf("Daemon is not running (%s)\n", daemon.SocketPath())
+			return nil
+		}
+		green.Print("Daemon is running")
+		dim.Printf(" (pid %d, database %s)\n", resp.PID, resp.Database)
+		return nil
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := daemon.Call(daemon.Request{Op: daemon.OpShutdown}); err != nil {
+			if err == daemon.ErrNotRunning {
+				yellow.Println("Daemon is not running")
+				return nil
+			}
+			return err
+		}
+		green.Println("✓ Daemon stopped")
+		return nil
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var migrateStatusFlag bool
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the command database",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Long: `Apply pending schema migrations to the command database. A backup copy of
+the database is written next to it before any migration runs.
+Examples:
+  kwik-cmd db migrate
+  kwik-cmd db migrate --status`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := db.Open(); err != nil {
+			return err
+		}
+		defer db.Close()
+
+		if migrateStatusFlag {
+			return printMigrationStatus()
+		}
+
+		applied, err := db.Migrate()
+		for _, m := range applied {
+			green.Printf("✓ Applied migration %d: %s\n", m.Version, m.Description)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			dim.Printf("Database is up to date (schema version %d)\n", db.LatestSchemaVersion())
+		}
+		return nil
+	},
+}
+
+var dbReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-parse stored commands",
+	Long: `Re-parse every stored command and rebuild its base, subcommand, segments,
+keywords, flags, template and argument values, and re-detect the git project of every directory that
+still exists. Run it after upgrading or after changing parser settings such
+as wrappers, so existing history is grouped the same way as new runs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		updated, projects, err := tracker.Reindex()
+		if err != nil {
+			return fmt.Errorf("failed to reindex: %w", err)
+		}
+		green.Printf("✓ Reindexed %d commands\n", updated)
+		if projects > 0 {
+			dim.Printf("  %d moved to a different project\n", projects)
+		}
+		return nil
+	},
+}
+
+func printMigrationStatus() error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return fmt.Errorf("failed to read migration status: %w", err)
+	}
+
+	bold.Println("=== Schema Migrations ===")
+	fmt.Printf("Database: %s\n", db.Path())
+	fmt.Print("Schema version: ")
+	cyan.Printf("%d", current)
+	fmt.Printf(" (latest %d)\n\n", db.LatestSchemaVersion())
+
+	for _, m := range status {
+		if m.AppliedAt != nil {
+			green.Printf("  [x] %3d  %s", m.Version, m.Description)
+			dim.Printf("  (applied %s)\n", m.AppliedAt.Local().Format("2006-01-02 15:04"))
+		} else {
+			yellow.Printf("  [ ] %3d  %s", m.Version, m.Description)
+			dim.Println("  (pending)")
+		}
+	}
+	return nil
+}
+
+func init() {
+	dbMigrateCmd.Flags().BoolVar(&migrateStatusFlag, "status", false, "Show applied and pending migrations without applying them")
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbReindexCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/kaustuvbot/kwik-cmd/internal/export"
 	"github.com/spf13/cobra"
 )
@@ -34,9 +36,19 @@ var exportCmd = &cobra.Command{
 var importCmd = &cobra.Command{
 	Use:   "import <filename>",
 	Short: "Import command history",
-	Args:  cobra.ExactArgs(1),
+	Long: `Import commands exported with 'kwik-cmd export'. Secrets are redacted and
+commands an ignore rule matches are skipped, as for tracked commands; nothing
+is imported while the shell is paused or incognito.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return export.ImportJSON(args[0])
+		imported, skipped, err := export.ImportJSON(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported: %d commands\n", imported)
+		fmt.Printf("Skipped: %d commands\n", skipped)
+		return nil
 	},
 }
 
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/fix"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixRun      bool
+	fixPlain    bool
+	fixHint     bool
+	fixExitCode int
+	fixLimit    int
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [command]",
+	Short: "Correct a mistyped command from the commands that have succeeded",
+	Long: `Suggest corrections for a command that failed because of a typo, e.g.
+"gti status" -> "git status" or "kubectl get pdos" -> "kubectl get pods". The
+base command and subcommand are compared with those that have succeeded
+before; flags and arguments are kept. Without a command, the last command of
+this shell session is corrected if it failed.
+Examples:
+  kwik-cmd fix
+  kwik-cmd fix --run
+  kwik-cmd fix "gti status"`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The hooks pass the command, so most hints end here without
+		// opening the database
+		if fixHint && (!config.Get().FixHints || len(args) == 1 && !fix.Correctable(fixExitCode, args[0])) {
+			return nil
+		}
+
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		var line string
+		if len(args) == 1 {
+			line = args[0]
+		} else {
+			last, err := db.LastExecution(os.Getenv("KWIK_CMD_SESSION"))
+			if err != nil {
+				return fmt.Errorf("failed to get last command: %w", err)
+			}
+			if last == nil || last.Success {
+				if !fixPlain {
+					yellow.Println("The last command did not fail; nothing to fix.")
+				}
+				return nil
+			}
+			line = last.FullCommand
+			if fixHint && !fix.Correctable(last.ExitCode, line) {
+				return nil
+			}
+		}
+		// Commands that are not tracked, such as cd, are not corrected from
+		// the tracked ones
+		if fixHint {
+			if _, ignored, _ := tracker.Ignored(line, tracker.GetCurrentDirectory()); ignored {
+				return nil
+			}
+		}
+
+		corrections, err := fix.Suggest(line, fixLimit)
+		if err != nil {
+			return fmt.Errorf("failed to find corrections: %w", err)
+		}
+
+		switch {
+		case fixHint:
+			// Shown by the shell hooks right after the failure
+			if len(corrections) > 0 {
+				fmt.Fprintf(os.Stderr, "kwik-cmd: did you mean '%s'? Run 'kwik-cmd fix --run' to run it.\n",
+					corrections[0].Command)
+			}
+			return nil
+		case fixPlain:
+			if len(corrections) > 0 {
+				fmt.Println(corrections[0].Command)
+			}
+			return nil
+		case len(corrections) == 0:
+			yellow.Printf("No correction found for '%s'.\n", line)
+			return nil
+		case fixRun:
+			return runCorrection(corrections[0].Command)
+		}
+
+		cyan.Print("=== Did you mean (for '")
+		fmt.Print(line)
+		cyan.Println("') ===")
+		for i, c := range corrections {
+			bold.Printf("  %d. ", i+1)
+			green.Print(c.Command)
+			edits := "edits"
+			if c.Distance == 1 {
+				edits = "edit"
+			}
+			dim.Printf(" (%d %s, succeeded %d times)\n", c.Distance, edits, c.Successes)
+		}
+		return nil
+	},
+}
+
+// runCorrection runs a corrected command line with the user's shell and
+// tracks the run like the hooks would
+func runCorrection(line string) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	bold.Print("Running: ")
+	green.Println(line)
+
+	run := exec.Command(shell, "-c", line)
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	start := time.Now()
+	runErr := run.Run()
+
+	exitCode := 0
+	if runErr != nil {
+		exitErr, ok := runErr.(*exec.ExitError)
+		if !ok {
+			return fmt.Errorf("failed to run %s: %w", line, runErr)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	e := tracker.Execution{
+		Success:  exitCode == 0,
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+	}
+	if _, err := tracker.Record(line, e.Resolve()); err != nil &&
+		!errors.Is(err, tracker.ErrIgnored) && !errors.Is(err, tracker.ErrPaused) {
+		return fmt.Errorf("failed to track %s: %w", line, err)
+	}
+	return runErr
+}
+
+func init() {
+	fixCmd.Flags().BoolVarP(&fixRun, "run", "r", false, "Run the best correction")
+	fixCmd.Flags().BoolVarP(&fixPlain, "plain", "p", false, "Print only the best correction, for scripts")
+	fixCmd.Flags().BoolVar(&fixHint, "hint", false, "Print a one-line hint to stderr if the command looks mistyped (used by the shell hooks)")
+	fixCmd.Flags().IntVarP(&fixExitCode, "exit-code", "e", 1, "Exit code the command failed with (with --hint)")
+	fixCmd.Flags().IntVarP(&fixLimit, "limit", "l", 5, "Maximum number of corrections to show")
+	rootCmd.AddCommand(fixCmd)
+}
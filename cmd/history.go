@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyLimit   int
+	historySession string
+	historyHere    bool
+	historySince   time.Duration
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the timeline of tracked command executions",
+	Long: `Show individual command executions, newest first, with the directory,
+exit code, duration and session they ran in.
+Examples:
+  kwik-cmd history
+  kwik-cmd history --here --since 24h
+  kwik-cmd history --session "$KWIK_CMD_SESSION"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		filter := db.ExecutionFilter{
+			SessionID: historySession,
+			Limit:     historyLimit,
+		}
+		if historyHere {
+			filter.Directory, _ = os.Getwd()
+		}
+		if historySince > 0 {
+			filter.Since = time.Now().Add(-historySince)
+		}
+
+		executions, err := db.GetExecutions(filter)
+		if err != nil {
+			return fmt.Errorf("failed to get executions: %w", err)
+		}
+
+		if len(executions) == 0 {
+			yellow.Println("No executions recorded yet.")
+			return nil
+		}
+
+		for _, e := range executions {
+			dim.Printf("%s ", e.ExecutedAt.Local().Format("2006-01-02 15:04:05"))
+			if e.Success {
+				green.Print(e.FullCommand)
+			} else {
+				yellow.Print(e.FullCommand)
+				dim.Printf(" [exit=%d]", e.ExitCode)
+			}
+			if e.DurationMs > 0 {
+				dim.Printf(" (%s)", (time.Duration(e.DurationMs) * time.Millisecond).String())
+			}
+			fmt.Println()
+			dim.Printf("    %s", e.Directory)
+			if e.SessionID != "" {
+				dim.Printf("  session=%s", e.SessionID)
+			}
+			if e.TTY != "" {
+				dim.Printf("  tty=%s", e.TTY)
+			}
+			if e.GitBranch != "" {
+				dim.Printf("  branch=%s", e.GitBranch)
+			}
+			if len(e.ProjectTypes) > 0 {
+				dim.Printf("  project=%s", strings.Join(e.ProjectTypes, ","))
+			}
+			fmt.Println()
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "l", 50, "Maximum number of executions to show")
+	historyCmd.Flags().StringVar(&historySession, "session", "", "Only show executions from this session")
+	historyCmd.Flags().BoolVar(&historyHere, "here", false, "Only show executions in the current directory")
+	historyCmd.Flags().DurationVar(&historySince, "since", 0, "Only show executions newer than this (e.g. 24h)")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var ignoreDir string
+
+var ignoreCmd = &cobra.Command{
+	Use:   "ignore",
+	Short: "Check the rules that keep commands from being tracked",
+	Long: `Commands are not tracked when an ignore rule of config matches them:
+  ignore_commands     commands such as cd and ls, when a line runs nothing else
+  ignore_patterns     globs of whole lines, or regular expressions prefixed with re:
+  ignore_directories  globs of directories, with their subdirectories
+  ignore_space        lines typed with a leading space
+  ignore_min_length   lines shorter than this many characters
+  ignore_max_length   lines longer than this many characters
+kwik-cmd's own commands are never tracked. The rules apply to the shell
+hooks, track, import-history and import alike.`,
+}
+
+var ignoreTestCmd = &cobra.Command{
+	Use:   "test \"<command>\"",
+	Short: "Show which ignore rule, if any, matches a command",
+	Long: `Show which ignore rule, if any, keeps a command from being tracked. Quote
+the command with a leading space to test ignore_space.
+Examples:
+  kwik-cmd ignore test "cd src && ls"
+  kwik-cmd ignore test " export TOKEN=abc"
+  kwik-cmd ignore test "make deploy" --dir ~/work/secret`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := tracker.GetCurrentDirectory()
+		if ignoreDir != "" {
+			dir, _ = filepath.Abs(config.ExpandPath(ignoreDir))
+		}
+		match, ignored, err := tracker.Ignored(args[0], dir)
+		if err != nil {
+			return fmt.Errorf("failed to read ignore rules: %w", err)
+		}
+		if !ignored {
+			green.Println("✓ Tracked: no ignore rule matches")
+			return nil
+		}
+		yellow.Printf("Ignored by %s\n", match.Rule)
+		if match.Detail != "" {
+			dim.Printf("  matched: %s\n", match.Detail)
+		}
+		return nil
+	},
+}
+
+func init() {
+	ignoreTestCmd.Flags().StringVarP(&ignoreDir, "dir", "d", "", "Directory the command runs in (defaults to the current one)")
+	ignoreCmd.AddCommand(ignoreTestCmd)
+	rootCmd.AddCommand(ignoreCmd)
+}
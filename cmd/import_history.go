@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -47,6 +48,15 @@ func importHistory() error {
 		return fmt.Errorf("Could not find history file. Use --file flag")
 	}
 
+	// Nothing is imported while tracking is paused
+	pause, err := tracker.CurrentPause(os.Getenv("KWIK_CMD_SESSION"))
+	if err != nil {
+		return err
+	}
+	if pause.Active() {
+		return fmt.Errorf("Not importing: tracking is %s", pause)
+	}
+
 	fmt.Printf("Importing from: %s\n", historyFile)
 
 	file, err := os.Open(historyFile)
@@ -59,23 +69,12 @@ func importHistory() error {
 	imported := 0
 	skipped := 0
 
-	ignored := map[string]bool{
-		"cd": true, "ls": true, "ll": true, "la": true, "lla": true,
-		"pwd": true, "echo": true, "exit": true, "export": true,
-		"declare": true, "typeset": true, "unset": true, "shift": true,
-		"local": true, "readonly": true, "help": true, "which": true,
-		"time": true, "fg": true, "bg": true, "jobs": true, "kill": true,
-		"builtin": true, "test": true, "[": true, "true": true,
-		"false": true, "logout": true, "shopt": true, "umask": true,
-		"set": true, "setenv": true, "printenv": true, "eval": true,
-		"exec": true, "source": true, "alias": true, "unalias": true,
-	}
-
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		// Leading whitespace is kept for the ignore_space rule
+		line := strings.TrimRight(scanner.Text(), " \t\r")
 		
 		// Skip empty lines
-		if line == "" {
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
@@ -83,31 +82,21 @@ func importHistory() error {
 		if strings.HasPrefix(line, ": ") {
 			parts := strings.SplitN(line, ";", 2)
 			if len(parts) > 1 {
-				line = strings.TrimSpace(parts[1])
+				line = parts[1]
 			}
 		}
 
 		// Skip comments and special commands
-		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
-			skipped++
-			continue
-		}
-
-		// Get base command
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
-		base := fields[0]
-
-		// Skip ignored
-		if ignored[base] {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
 			skipped++
 			continue
 		}
 
-		// Track command
+		// Track command; the ignore rules of config apply as for the hooks
 		if err := tracker.TrackCommand(line); err != nil {
+			if errors.Is(err, tracker.ErrIgnored) {
+				skipped++
+			}
 			// Silently continue on error
 			continue
 		}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note \"<command>\" [note]",
+	Short: "Attach a note to a command, found by search",
+	Long: `Attach a note to a tracked command, e.g. what it is for. Notes are part of
+the full-text index, so 'kwik-cmd search' finds a command by the words of its
+note. Without a note the current one is shown; an empty note removes it.
+Examples:
+  kwik-cmd note "kubectl rollout restart deploy/api" "restart api after config change"
+  kwik-cmd note "kubectl rollout restart deploy/api"
+  kwik-cmd note "kubectl rollout restart deploy/api" ""`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		if len(args) == 1 {
+			note, err := db.GetNote(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read note: %w", err)
+			}
+			if note == "" {
+				fmt.Println("No note.")
+			} else {
+				fmt.Println(note)
+			}
+			return nil
+		}
+
+		n, err := db.SetNote(args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("failed to set note: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("command not found in history: %s", args[0])
+		}
+		if args[1] == "" {
+			fmt.Println("Note removed.")
+		} else {
+			fmt.Println("Note saved.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+}
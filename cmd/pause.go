@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var pauseFor time.Duration
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop tracking commands in this shell until resume",
+	Long: `Stop tracking the commands of the current shell session, for a while with
+--for or until 'kwik-cmd resume'. Nothing run meanwhile is stored; other
+shells keep being tracked. The shell hooks set $KWIK_CMD_PROMPT to show it in
+your prompt.
+Examples:
+  kwik-cmd pause
+  kwik-cmd pause --for 30m`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pauseFor < 0 {
+			return fmt.Errorf("--for must be positive, got %s", pauseFor)
+		}
+		state, err := tracker.Pause(os.Getenv("KWIK_CMD_SESSION"), pauseFor)
+		if err != nil {
+			return fmt.Errorf("failed to pause tracking: %w", err)
+		}
+		yellow.Printf("⏸ Tracking %s\n", state)
+		return nil
+	},
+}
+
+var incognitoCmd = &cobra.Command{
+	Use:   "incognito",
+	Short: "Stop tracking commands in this shell until it exits",
+	Long: `Stop tracking the commands of the current shell session until the shell
+exits or 'kwik-cmd resume' is run in it. Other shells keep being tracked.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := tracker.Incognito(os.Getenv("KWIK_CMD_SESSION")); err != nil {
+			return fmt.Errorf("failed to go incognito: %w", err)
+		}
+		yellow.Println("⏸ This shell is incognito: nothing is tracked until it exits or kwik-cmd resume")
+		return nil
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume tracking after pause or incognito",
+	Long: `End a pause, and the incognito mode of the current shell session, so
+commands are tracked again.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := tracker.Resume(os.Getenv("KWIK_CMD_SESSION"))
+		if err != nil {
+			return fmt.Errorf("failed to resume tracking: %w", err)
+		}
+		if !state.Active() {
+			dim.Println("Tracking was not paused.")
+			return nil
+		}
+		green.Println("✓ Tracking resumed")
+		return nil
+	},
+}
+
+func init() {
+	pauseCmd.Flags().DurationVarP(&pauseFor, "for", "f", 0, "Resume automatically after this long (e.g. 30m, 2h)")
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(incognitoCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	predictPlain   bool
+	predictLimit   int
+	predictSession string
+	predictAfter   string
+)
+
+var predictCmd = &cobra.Command{
+	Use:   "predict [prefix]",
+	Short: "Predict the next command from what usually follows the last one",
+	Long: `Suggest the commands that usually follow the last command tracked in this
+shell session, learned from the order commands are run in each session and
+directory. A prefix narrows the predictions to commands starting with it.
+Examples:
+  kwik-cmd predict
+  kwik-cmd predict "git"
+  kwik-cmd predict --after "terraform plan"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("limit") {
+			predictLimit = config.Get().MaxSuggestions
+		}
+		prefix := ""
+		if len(args) == 1 {
+			prefix = strings.TrimSpace(args[0])
+		}
+
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		if predictSession == "" {
+			predictSession = os.Getenv("KWIK_CMD_SESSION")
+		}
+		after := predictAfter
+		if after == "" {
+			last, err := db.LastExecution(predictSession)
+			if err != nil {
+				return fmt.Errorf("failed to get last command: %w", err)
+			}
+			if last == nil {
+				if !predictPlain {
+					yellow.Println("No commands tracked yet in this session.")
+				}
+				return nil
+			}
+			after = last.FullCommand
+		}
+
+		predictions, err := db.PredictNext(after, tracker.GetCurrentDirectory(), 0)
+		if err != nil {
+			return fmt.Errorf("failed to predict: %w", err)
+		}
+		var matched []db.Prediction
+		for _, p := range predictions {
+			if strings.HasPrefix(strings.ToLower(p.FullCommand), strings.ToLower(prefix)) {
+				matched = append(matched, p)
+			}
+		}
+		if predictLimit > 0 && len(matched) > predictLimit {
+			matched = matched[:predictLimit]
+		}
+
+		if predictPlain {
+			for _, p := range matched {
+				fmt.Println(p.FullCommand)
+			}
+			return nil
+		}
+
+		if len(matched) == 0 {
+			yellow.Printf("Nothing learned yet about what follows '%s'.\n", after)
+			return nil
+		}
+		cyan.Print("=== After '")
+		fmt.Print(after)
+		cyan.Println("' ===")
+		for i, p := range matched {
+			bold.Printf("  %d. ", i+1)
+			green.Print(p.FullCommand)
+			dim.Printf(" (%.0f%%, followed %d times)\n", p.Probability*100, p.Count)
+		}
+		return nil
+	},
+}
+
+func init() {
+	predictCmd.Flags().BoolVarP(&predictPlain, "plain", "p", false, "Output plain text (one command per line, no colors/headers)")
+	predictCmd.Flags().IntVarP(&predictLimit, "limit", "l", 10, "Maximum number of predictions (default: max_suggestions from config)")
+	predictCmd.Flags().StringVar(&predictSession, "session", "", "Shell session whose last command to predict from (defaults to $KWIK_CMD_SESSION)")
+	predictCmd.Flags().StringVar(&predictAfter, "after", "", "Predict what follows this command instead of the last tracked one")
+	rootCmd.AddCommand(predictCmd)
+}
@@ -4,15 +4,44 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/fatih/color"
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
 	"github.com/spf13/cobra"
 )
 
+var dbPathFlag string
+
 var rootCmd = &cobra.Command{
 	Use:   "kwik-cmd",
 	Short: "A high-performance CLI tool for command tracking and intelligent suggestions",
 	Long: `kwik-cmd tracks terminal commands automatically, learns usage patterns,
-and suggests intelligent command completions. Works natively with Bash and Zsh.`,
+and suggests intelligent command completions. Works natively with Bash and Zsh.
+
+The config file defaults to ~/.kwik-cmd/config.yaml and can be moved with
+$KWIK_CMD_CONFIG. The database location is taken from --db, then $KWIK_CMD_DB,
+then database_path in the config file.`,
 	Version: "0.1.0",
+	// Execute prints the error itself
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			// Let the config subcommands run so a broken file can be fixed
+			if cmd.Parent() != configCmd {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			cfg = config.Default()
+		}
+		if dbPathFlag != "" {
+			config.SetDatabasePath(dbPathFlag)
+		}
+		parser.SetWrappers(cfg.Wrappers)
+		if !cfg.EnableColors {
+			color.NoColor = true
+		}
+		return nil
+	},
 }
 
 func Execute() {
@@ -23,6 +52,8 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&dbPathFlag, "db", "", "Database file to use (overrides $KWIK_CMD_DB and database_path)")
+
 	rootCmd.AddCommand(trackCmd)
 	rootCmd.AddCommand(suggestCmd)
 	rootCmd.AddCommand(searchCmd)
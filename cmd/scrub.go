@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+	"github.com/spf13/cobra"
+)
+
+var scrubDryRun bool
+
+var scrubCmd = &cobra.Command{
+	Use:   "scrub",
+	Short: "Redact secrets already stored in the history",
+	Long: `Redact the tokens, passwords and other secrets in commands tracked before
+redaction was turned on or before a redact_patterns rule was added. Commands,
+their executions, notes and next-command statistics are rewritten with the
+secrets replaced by REDACTED, and the database file is compacted so the old
+texts do not linger in it. Commands that become identical are merged.
+Examples:
+  kwik-cmd scrub --dry-run
+  kwik-cmd scrub`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		result, err := tracker.Scrub(scrubDryRun)
+		if err != nil {
+			return fmt.Errorf("failed to scrub history: %w", err)
+		}
+		if !result.Changed() {
+			green.Println("✓ No secrets found in the history")
+			return nil
+		}
+
+		for _, c := range result.Commands {
+			fmt.Printf("  %s\n", c)
+		}
+		if scrubDryRun {
+			yellow.Printf("Would redact %d commands, %d notes and %d transitions\n",
+				len(result.Commands), result.Notes, result.Transitions)
+			return nil
+		}
+		green.Printf("✓ Redacted %d commands, %d notes and %d transitions\n",
+			len(result.Commands), result.Notes, result.Transitions)
+		if result.Merged > 0 {
+			dim.Printf("  %d merged into identical commands\n", result.Merged)
+		}
+
+		// Backups taken before migrations still hold the secrets
+		if backups, _ := filepath.Glob(db.Path() + ".v*.bak"); len(backups) > 0 {
+			yellow.Println("These backups still contain the secrets; delete them if they are not needed:")
+			for _, b := range backups {
+				fmt.Printf("  %s\n", b)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	scrubCmd.Flags().BoolVarP(&scrubDryRun, "dry-run", "n", false, "Show what would be redacted without changing anything")
+	rootCmd.AddCommand(scrubCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/kaustuvbot/kwik-cmd/internal/daemon"
 	"github.com/kaustuvbot/kwik-cmd/internal/suggester"
 	"github.com/spf13/cobra"
 )
@@ -15,10 +16,28 @@ var (
 var searchCmd = &cobra.Command{
 	Use:   "search \"<keywords>\"",
 	Short: "Search commands by keywords",
-	Args:  cobra.ExactArgs(1),
+	Long: `Search the full-text index of commands: their text, arguments, keywords,
+flags and flag meanings, directory and notes, best match (BM25) first.
+
+  docker build         commands containing both words
+  "fix login"          the phrase
+  dock*                words starting with dock
+  docker OR podman     either word; AND and parentheses work too
+  build NOT docker     the first without the second (also build AND NOT docker)
+  dir:~/src/api        run in ~/src/api or below it
+  exit:0, exit:fail    with a run that exited 0, or that failed
+  since:2d             run in the last 2 days (3h, 1w, 2024-01-31 ...)
+
+Plain words that no command contains are matched fuzzily, so "kgp" finds
+"kubectl get pods".`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if searchPlainFlag {
-			commands, err := suggester.SearchPlain(args[0], searchLimitFlag)
+			commands, err := daemon.Search(args[0], searchLimitFlag)
+			if err == daemon.ErrNotRunning {
+				commands, err = suggester.SearchPlain(args[0], searchLimitFlag)
+			}
 			if err != nil {
 				return err
 			}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/daemon"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
 	"github.com/kaustuvbot/kwik-cmd/internal/suggester"
 	"github.com/spf13/cobra"
 )
@@ -11,6 +15,10 @@ var (
 	plainFlag  bool
 	splitFlag  bool
 	limitFlag  int
+
+	includeFailedFlag bool
+	explainFlag       bool
+	suggestJSONFlag   bool
 )
 
 var suggestCmd = &cobra.Command{
@@ -18,8 +26,14 @@ var suggestCmd = &cobra.Command{
 	Short: "Get command suggestions for partial input",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("limit") {
+			limitFlag = config.Get().MaxSuggestions
+		}
+		if explainFlag || suggestJSONFlag {
+			return suggester.Explain(args[0], limitFlag, includeFailedFlag, suggestJSONFlag)
+		}
 		if splitFlag {
-			result, err := suggester.SuggestPlainSplit(args[0], limitFlag)
+			result, err := suggester.SuggestPlainSplit(args[0], limitFlag, includeFailedFlag)
 			if err != nil {
 				return err
 			}
@@ -34,7 +48,18 @@ var suggestCmd = &cobra.Command{
 			return nil
 		}
 		if plainFlag {
-			commands, err := suggester.SuggestPlain(args[0], limitFlag)
+			currentDir, _ := os.Getwd()
+			rq := db.RankQuery{
+				Partial:       args[0],
+				Directory:     currentDir,
+				SessionID:     os.Getenv("KWIK_CMD_SESSION"),
+				Limit:         limitFlag,
+				IncludeFailed: includeFailedFlag,
+			}
+			commands, err := daemon.Suggest(rq)
+			if err == daemon.ErrNotRunning {
+				commands, err = suggester.SuggestPlain(rq)
+			}
 			if err != nil {
 				return err
 			}
@@ -43,12 +68,15 @@ var suggestCmd = &cobra.Command{
 			}
 			return nil
 		}
-		return suggester.Suggest(args[0])
+		return suggester.Suggest(args[0], limitFlag, includeFailedFlag)
 	},
 }
 
 func init() {
 	suggestCmd.Flags().BoolVarP(&plainFlag, "plain", "p", false, "Output plain text (one command per line, no colors/headers)")
 	suggestCmd.Flags().BoolVarP(&splitFlag, "split", "s", false, "Output split by recent and frequent (for shell integration)")
-	suggestCmd.Flags().IntVarP(&limitFlag, "limit", "l", 10, "Maximum number of suggestions to return")
+	suggestCmd.Flags().IntVarP(&limitFlag, "limit", "l", 10, "Maximum number of suggestions to return (default: max_suggestions from config)")
+	suggestCmd.Flags().BoolVar(&includeFailedFlag, "include-failed", false, "Include commands that have never succeeded")
+	suggestCmd.Flags().BoolVar(&explainFlag, "explain", false, "Break down each suggestion's score into its signals and their inputs")
+	suggestCmd.Flags().BoolVar(&suggestJSONFlag, "json", false, "Output the --explain breakdown as JSON")
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var templatePlain bool
+
+var templateCmd = &cobra.Command{
+	Use:   "template \"<command>\"",
+	Short: "Show the template of a command and its placeholders",
+	Long: `Show the template a command is generalized into, with the arguments that
+vary between runs replaced by placeholders: IPs, hashes, UUIDs, numbers, paths
+and URLs, and the arguments the built-in grammars name, such as the pod and
+namespace in "kubectl logs pod-abc123 -n prod". Commands sharing a template
+are counted together by stats and analyze and suggested once.
+Examples:
+  kwik-cmd template "kubectl logs pod-abc123 -n prod"
+  kwik-cmd template --plain "ssh deploy@10.0.0.12"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed := parser.ParseCommand(args[0])
+		if parsed == nil {
+			return fmt.Errorf("failed to parse command")
+		}
+		placeholders := parsed.AllPlaceholders()
+
+		// One "name<TAB>value" line per placeholder, for the shell
+		// integration to tab through
+		if templatePlain {
+			for _, ph := range placeholders {
+				fmt.Printf("%s\t%s\n", ph.Name, ph.Value)
+			}
+			return nil
+		}
+
+		template := parsed.Template()
+		bold.Print("Template: ")
+		green.Println(template)
+		if len(placeholders) == 0 {
+			dim.Println("Nothing varies between runs of this command.")
+			return nil
+		}
+		for _, ph := range placeholders {
+			cyan.Printf("  {%s}", ph.Name)
+			fmt.Printf(" = %s\n", ph.Value)
+		}
+
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		variants, err := db.LatestVariants([]string{template})
+		if err != nil {
+			return fmt.Errorf("failed to get template usage: %w", err)
+		}
+		if v, ok := variants[template]; ok {
+			dim.Printf("%d variants in history, run %d times, last: %s\n", v.Variants, v.RunCount, v.Latest)
+		}
+		return nil
+	},
+}
+
+func init() {
+	templateCmd.Flags().BoolVarP(&templatePlain, "plain", "p", false, "Output one name<TAB>value line per placeholder")
+	rootCmd.AddCommand(templateCmd)
+}
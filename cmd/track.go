@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/daemon"
 	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
 	"github.com/spf13/cobra"
 )
 
 var (
-	trackSuccess bool
+	trackSuccess  bool
 	trackExitCode int
+	trackDuration time.Duration
+	trackSession  string
+	trackTTY      string
 )
 
 var trackCmd = &cobra.Command{
@@ -17,14 +24,57 @@ var trackCmd = &cobra.Command{
 Examples:
   kwik-cmd track "git commit -m 'fix bug'"
   kwik-cmd track "docker build" --exit-code 0
-  kwik-cmd track "npm test" --exit-code 1`,
-	Args: cobra.ExactArgs(1),
+  kwik-cmd track "npm test" --exit-code 1
+  kwik-cmd track "make" --exit-code 0 --duration 12s --session "$KWIK_CMD_SESSION"`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return tracker.TrackCommandWithStatus(args[0], trackSuccess, trackExitCode)
+		e := tracker.Execution{
+			Success:   trackSuccess && trackExitCode == 0,
+			ExitCode:  trackExitCode,
+			Duration:  trackDuration,
+			SessionID: trackSession,
+			TTY:       trackTTY,
+		}
+
+		// Pauses and ignore rules are checked before the run reaches the
+		// daemon so that the reason can be reported
+		session := trackSession
+		if session == "" {
+			session = os.Getenv("KWIK_CMD_SESSION")
+		}
+		if pause, err := tracker.CurrentPause(session); err != nil {
+			return err
+		} else if pause.Active() {
+			dim.Printf("Not tracked: %s\n", pause)
+			return nil
+		}
+		if match, ignored, err := tracker.Ignored(args[0], tracker.GetCurrentDirectory()); err != nil {
+			return err
+		} else if ignored {
+			dim.Printf("Not tracked: ignored by %s\n", match)
+			return nil
+		}
+
+		// Prefer the daemon's open connection; fall back to the database
+		// only when none is running, since a daemon that timed out may still
+		// commit the run and writing it here too would count it twice
+		fullCmd, err := daemon.Track(args[0], e)
+		if err == daemon.ErrNotRunning {
+			return tracker.TrackExecution(args[0], e)
+		}
+		if err != nil {
+			return err
+		}
+		tracker.PrintTracked(fullCmd, e.Success, e.ExitCode)
+		return nil
 	},
 }
 
 func init() {
 	trackCmd.Flags().BoolVarP(&trackSuccess, "success", "s", true, "Command succeeded")
 	trackCmd.Flags().IntVarP(&trackExitCode, "exit-code", "e", 0, "Command exit code")
+	trackCmd.Flags().DurationVarP(&trackDuration, "duration", "d", 0, "How long the command ran (e.g. 350ms, 2m)")
+	trackCmd.Flags().StringVar(&trackSession, "session", "", "Shell session id (defaults to $KWIK_CMD_SESSION)")
+	trackCmd.Flags().StringVar(&trackTTY, "tty", "", "Terminal the command ran on")
 }
@@ -1,92 +1,203 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
 	"github.com/spf13/viper"
 )
 
+// Environment variables that override the config file
+const (
+	EnvConfigPath   = "KWIK_CMD_CONFIG"
+	EnvDatabasePath = "KWIK_CMD_DB"
+)
+
 type Config struct {
-	DatabasePath string `mapstructure:"database_path"`
-	MaxSuggestions int `mapstructure:"max_suggestions"`
-	RecencyWeight float64 `mapstructure:"recency_weight"`
-	FrequencyWeight float64 `mapstructure:"frequency_weight"`
-	DirectoryWeight float64 `mapstructure:"directory_weight"`
-	EnableColors bool `mapstructure:"enable_colors"`
-	ShellIntegration string `mapstructure:"shell_integration"`
+	DatabasePath     string   `mapstructure:"database_path"`
+	MaxSuggestions   int      `mapstructure:"max_suggestions"`
+	RecencyWeight    float64  `mapstructure:"recency_weight"`
+	FrequencyWeight  float64  `mapstructure:"frequency_weight"`
+	DirectoryWeight  float64  `mapstructure:"directory_weight"`
+	EnableColors     bool     `mapstructure:"enable_colors"`
+	ShellIntegration string   `mapstructure:"shell_integration"`
+	BusyTimeoutMs    int      `mapstructure:"busy_timeout_ms"`
+	Wrappers         []string `mapstructure:"wrappers"`
+	// FrecencyHalfLifeDays is how long it takes for a run to count half as
+	// much in the frequency signal
+	FrecencyHalfLifeDays float64 `mapstructure:"frecency_half_life_days"`
+	// ProjectTypeWeight is the weight of commands run in other projects of
+	// the same kind (go, node, ...) as the current directory
+	ProjectTypeWeight float64 `mapstructure:"project_type_weight"`
+	// PredictionWeight is the weight of the commands that usually follow
+	// the previous one, used when nothing has been typed yet
+	PredictionWeight float64 `mapstructure:"prediction_weight"`
+	// FailureWeight is how much a command's recent failure rate lowers its
+	// score
+	FailureWeight float64 `mapstructure:"failure_weight"`
+	// FixHints makes the shell hooks suggest a correction after a command
+	// fails with what looks like a typo
+	FixHints bool `mapstructure:"fix_hints"`
+	// Redact replaces secrets in commands before they are stored (see
+	// internal/redact); RedactPatterns are further regular expressions of
+	// secrets
+	Redact         bool     `mapstructure:"redact"`
+	RedactPatterns []string `mapstructure:"redact_patterns"`
+	// The ignore rules decide which command runs are not tracked (see
+	// internal/ignore)
+	IgnoreCommands    []string `mapstructure:"ignore_commands"`
+	IgnorePatterns    []string `mapstructure:"ignore_patterns"`
+	IgnoreDirectories []string `mapstructure:"ignore_directories"`
+	IgnoreSpace       bool     `mapstructure:"ignore_space"`
+	IgnoreMinLength   int      `mapstructure:"ignore_min_length"`
+	IgnoreMaxLength   int      `mapstructure:"ignore_max_length"`
 }
 
-var cfg *Config
+var (
+	cfg        *Config
+	configFile string
+)
 
-func Load() (*Config, error) {
+// Dir returns the kwik-cmd data directory (~/.kwik-cmd)
+func Dir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".kwik-cmd"), nil
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	dir, err := Dir()
+	if err != nil {
+		dir = ".kwik-cmd"
+	}
+	return &Config{
+		DatabasePath:         filepath.Join(dir, "commands.db"),
+		MaxSuggestions:       10,
+		RecencyWeight:        0.4,
+		FrequencyWeight:      0.4,
+		DirectoryWeight:      0.2,
+		EnableColors:         true,
+		ShellIntegration:     "auto",
+		BusyTimeoutMs:        5000,
+		Wrappers:             parser.DefaultWrappers(),
+		FrecencyHalfLifeDays: 7,
+		ProjectTypeWeight:    0.15,
+		PredictionWeight:     0.5,
+		FailureWeight:        0.3,
+		FixHints:             true,
+		Redact:               true,
+		RedactPatterns:       []string{},
+		IgnoreCommands: []string{
+			"cd", "ls", "ll", "la", "lla", "pwd", "echo", "exit", "logout",
+			"export", "declare", "typeset", "local", "readonly", "set", "unset",
+			"setenv", "setx", "shift", "shopt", "umask", "printenv", "alias",
+			"unalias", "source", "eval", "exec", "builtin", "help", "which",
+			"what", "time", "fg", "bg", "jobs", "kill", "test", "[", "true",
+			"false",
+		},
+		IgnorePatterns:    []string{},
+		IgnoreDirectories: []string{},
+		IgnoreSpace:       true,
+		IgnoreMinLength:   2,
+		IgnoreMaxLength:   2000,
+	}
+}
+
+// Load reads the config file ($KWIK_CMD_CONFIG or ~/.kwik-cmd/config.yaml),
+// writing the defaults on first run. $KWIK_CMD_DB overrides database_path.
+func Load() (*Config, error) {
+	configDir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile = os.Getenv(EnvConfigPath)
+	if configFile == "" {
+		configFile = filepath.Join(configDir, "config.yaml")
 	}
+	configFile = ExpandPath(configFile)
 
-	configDir := filepath.Join(homeDir, ".kwik-cmd")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	configPath := filepath.Join(configDir, "config")
-	viper.SetConfigName("config")
+	viper.SetConfigFile(configFile)
 	viper.SetConfigType("yaml")
-	viper.AddConfigPath(configDir)
-	viper.AddConfigPath(".")
 
 	// Set defaults
-	viper.SetDefault("database_path", filepath.Join(homeDir, ".kwik-cmd", "commands.db"))
-	viper.SetDefault("max_suggestions", 10)
-	viper.SetDefault("recency_weight", 0.4)
-	viper.SetDefault("frequency_weight", 0.4)
-	viper.SetDefault("directory_weight", 0.2)
-	viper.SetDefault("enable_colors", true)
-	viper.SetDefault("shell_integration", "auto")
+	defaults := Default()
+	for name, value := range defaults.values() {
+		viper.SetDefault(name, value)
+	}
 
 	// Try to read config
 	if err := viper.ReadInConfig(); err != nil {
-		// Config doesn't exist, create default
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			cfg = &Config{
-				DatabasePath:    filepath.Join(homeDir, ".kwik-cmd", "commands.db"),
-				MaxSuggestions: 10,
-				RecencyWeight:   0.4,
-				FrequencyWeight: 0.4,
-				DirectoryWeight: 0.2,
-				EnableColors:    true,
-				ShellIntegration: "auto",
-			}
-			if err := saveConfig(configPath, cfg); err != nil {
-				return cfg, nil // Return default config anyway
-			}
-			return cfg, nil
+		var notFound viper.ConfigFileNotFoundError
+		if !os.IsNotExist(err) && !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read %s: %w", configFile, err)
 		}
-		return nil, err
+		// Config doesn't exist, create default (and use defaults if that fails)
+		_ = saveConfig(configFile, defaults)
 	}
 
-	cfg = &Config{}
-	if err := viper.Unmarshal(cfg); err != nil {
+	loaded := &Config{}
+	if err := viper.Unmarshal(loaded); err != nil {
 		return nil, err
 	}
 
+	// Environment override, applied after the file so it always wins
+	if path := os.Getenv(EnvDatabasePath); path != "" {
+		loaded.DatabasePath = path
+	}
+	loaded.DatabasePath = ExpandPath(loaded.DatabasePath)
+
+	cfg = loaded
 	return cfg, nil
 }
 
 func saveConfig(path string, cfg *Config) error {
-	viper.Set("database_path", cfg.DatabasePath)
-	viper.Set("max_suggestions", cfg.MaxSuggestions)
-	viper.Set("recency_weight", cfg.RecencyWeight)
-	viper.Set("frequency_weight", cfg.FrequencyWeight)
-	viper.Set("directory_weight", cfg.DirectoryWeight)
-	viper.Set("enable_colors", cfg.EnableColors)
-	viper.Set("shell_integration", cfg.ShellIntegration)
-
-	return viper.WriteConfigAs(path + ".yaml")
+	for name, value := range cfg.values() {
+		viper.Set(name, value)
+	}
+
+	return viper.WriteConfigAs(path)
 }
 
+// Get returns the loaded config, loading it on first use. If the config
+// cannot be read the built-in defaults are returned.
 func Get() *Config {
+	if cfg == nil {
+		if _, err := Load(); err != nil {
+			cfg = Default()
+		}
+	}
 	return cfg
 }
+
+// SetDatabasePath overrides the database location for this process, e.g.
+// from the --db flag
+func SetDatabasePath(path string) {
+	Get().DatabasePath = ExpandPath(path)
+}
+
+// File returns the path of the config file in use
+func File() string {
+	return configFile
+}
+
+// ExpandPath expands a leading ~ to the user's home directory
+func ExpandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
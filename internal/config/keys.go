@@ -0,0 +1,506 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Value types understood by the config subcommands
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	// TypeList is a list of strings, written comma separated on the command
+	// line and as a YAML sequence in the file
+	TypeList = "list"
+)
+
+// Key describes a single config setting
+type Key struct {
+	Name        string
+	Type        string
+	Description string
+	// check validates a parsed value in isolation; cross-key rules live in
+	// Config.Validate
+	check func(value interface{}) error
+}
+
+var keys = []Key{
+	{
+		Name:        "database_path",
+		Type:        TypeString,
+		Description: "SQLite database file",
+		check:       nonEmpty,
+	},
+	{
+		Name:        "max_suggestions",
+		Type:        TypeInt,
+		Description: "Default number of suggestions to show",
+		check:       intRange(1, 100),
+	},
+	{
+		Name:        "recency_weight",
+		Type:        TypeFloat,
+		Description: "Weight of the recency signal (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "frequency_weight",
+		Type:        TypeFloat,
+		Description: "Weight of the frequency signal (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "directory_weight",
+		Type:        TypeFloat,
+		Description: "Weight of the directory signal (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "enable_colors",
+		Type:        TypeBool,
+		Description: "Colored terminal output",
+	},
+	{
+		Name:        "shell_integration",
+		Type:        TypeString,
+		Description: "Shell integration mode (auto, zsh, bash, none)",
+		check:       oneOf("auto", "zsh", "bash", "none"),
+	},
+	{
+		Name:        "busy_timeout_ms",
+		Type:        TypeInt,
+		Description: "How long a writer waits for a locked database (ms)",
+		check:       intRange(0, 60000),
+	},
+	{
+		Name:        "failure_weight",
+		Type:        TypeFloat,
+		Description: "Score penalty for commands that failed recently (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "fix_hints",
+		Type:        TypeBool,
+		Description: "Show a did-you-mean hint after a mistyped command fails",
+	},
+	{
+		Name:        "frecency_half_life_days",
+		Type:        TypeFloat,
+		Description: "Days after which a run counts half as much in ranking",
+		check:       floatRange(0.01, 3650),
+	},
+	{
+		Name:        "project_type_weight",
+		Type:        TypeFloat,
+		Description: "Weight of commands run in projects of the same kind (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "prediction_weight",
+		Type:        TypeFloat,
+		Description: "Weight of next-command prediction when nothing is typed yet (0-1)",
+		check:       floatRange(0, 1),
+	},
+	{
+		Name:        "wrappers",
+		Type:        TypeList,
+		Description: "Commands peeled off to find the command they run (sudo, env, ...)",
+		check:       names,
+	},
+	{
+		Name:        "redact",
+		Type:        TypeBool,
+		Description: "Replace tokens, passwords and other secrets in commands before storing them",
+	},
+	{
+		Name:        "redact_patterns",
+		Type:        TypeList,
+		Description: "Regular expressions of further secrets to redact (the first group if any)",
+		check:       patterns,
+	},
+	{
+		Name:        "ignore_commands",
+		Type:        TypeList,
+		Description: "Commands not tracked when a line runs nothing else (cd, ls, ...)",
+		check:       names,
+	},
+	{
+		Name:        "ignore_patterns",
+		Type:        TypeList,
+		Description: "Globs of command lines not to track, or regular expressions prefixed with re:",
+		check:       ignorePatterns,
+	},
+	{
+		Name:        "ignore_directories",
+		Type:        TypeList,
+		Description: "Globs of directories, with their subdirectories, where nothing is tracked",
+	},
+	{
+		Name:        "ignore_space",
+		Type:        TypeBool,
+		Description: "Do not track commands typed with a leading space",
+	},
+	{
+		Name:        "ignore_min_length",
+		Type:        TypeInt,
+		Description: "Shortest command line to track in characters (0 for no limit)",
+		check:       intRange(0, 1000),
+	},
+	{
+		Name:        "ignore_max_length",
+		Type:        TypeInt,
+		Description: "Longest command line to track in characters (0 for no limit)",
+		check:       intRange(0, 1000000),
+	},
+}
+
+// weightSumTolerance is how far the ranking weights may drift from 1.0
+const weightSumTolerance = 0.05
+
+// Keys returns every known config key in a stable order
+func Keys() []Key {
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// LookupKey returns the definition of a config key
+func LookupKey(name string) (Key, error) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+	}
+	return Key{}, fmt.Errorf("unknown config key %q (see 'kwik-cmd config list')", name)
+}
+
+// Parse converts a raw string into the key's type and checks its range
+func (k Key) Parse(raw string) (interface{}, error) {
+	var value interface{}
+	var err error
+
+	switch k.Type {
+	case TypeInt:
+		value, err = strconv.Atoi(raw)
+	case TypeFloat:
+		value, err = strconv.ParseFloat(raw, 64)
+	case TypeBool:
+		value, err = strconv.ParseBool(raw)
+	case TypeList:
+		value = splitList(raw)
+	default:
+		value = raw
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: expected %s, got %q", k.Name, k.Type, raw)
+	}
+
+	if k.check != nil {
+		if err := k.check(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", k.Name, err)
+		}
+	}
+	return value, nil
+}
+
+// Value returns the current value of the key from the config file (or its
+// default), typed according to the key
+func (k Key) Value() interface{} {
+	switch k.Type {
+	case TypeInt:
+		return viper.GetInt(k.Name)
+	case TypeFloat:
+		return viper.GetFloat64(k.Name)
+	case TypeBool:
+		return viper.GetBool(k.Name)
+	case TypeList:
+		return viper.GetStringSlice(k.Name)
+	default:
+		return viper.GetString(k.Name)
+	}
+}
+
+// FormatValue renders a config value the way it is written on the command
+// line; lists are comma separated
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// splitList parses a comma separated list, dropping blank items
+func splitList(raw string) []string {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Default returns the built-in value of the key
+func (k Key) Default() interface{} {
+	return defaultValues()[k.Name]
+}
+
+func defaultValues() map[string]interface{} {
+	return Default().values()
+}
+
+// values maps each config key to its value in c
+func (c *Config) values() map[string]interface{} {
+	return map[string]interface{}{
+		"database_path":           c.DatabasePath,
+		"max_suggestions":         c.MaxSuggestions,
+		"recency_weight":          c.RecencyWeight,
+		"frequency_weight":        c.FrequencyWeight,
+		"directory_weight":        c.DirectoryWeight,
+		"enable_colors":           c.EnableColors,
+		"shell_integration":       c.ShellIntegration,
+		"busy_timeout_ms":         c.BusyTimeoutMs,
+		"wrappers":                c.Wrappers,
+		"frecency_half_life_days": c.FrecencyHalfLifeDays,
+		"project_type_weight":     c.ProjectTypeWeight,
+		"prediction_weight":       c.PredictionWeight,
+		"failure_weight":          c.FailureWeight,
+		"fix_hints":               c.FixHints,
+		"redact":                  c.Redact,
+		"redact_patterns":         c.RedactPatterns,
+		"ignore_commands":         c.IgnoreCommands,
+		"ignore_patterns":         c.IgnorePatterns,
+		"ignore_directories":      c.IgnoreDirectories,
+		"ignore_space":            c.IgnoreSpace,
+		"ignore_min_length":       c.IgnoreMinLength,
+		"ignore_max_length":       c.IgnoreMaxLength,
+	}
+}
+
+// Validate checks every setting and the rules that span several keys. It
+// returns one error per problem found.
+func (c *Config) Validate() []error {
+	values := c.values()
+
+	var errs []error
+	for _, k := range Keys() {
+		if k.check == nil {
+			continue
+		}
+		if err := k.check(values[k.Name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", k.Name, err))
+		}
+	}
+
+	sum := c.RecencyWeight + c.FrequencyWeight + c.DirectoryWeight
+	if math.Abs(sum-1) > weightSumTolerance {
+		errs = append(errs, fmt.Errorf("recency_weight + frequency_weight + directory_weight = %.2f, should sum to 1.0", sum))
+	}
+	if c.IgnoreMaxLength > 0 && c.IgnoreMinLength > c.IgnoreMaxLength {
+		errs = append(errs, fmt.Errorf("ignore_min_length %d is greater than ignore_max_length %d", c.IgnoreMinLength, c.IgnoreMaxLength))
+	}
+
+	return errs
+}
+
+// ValidateFile reads the config file fresh and validates it, including
+// checks for unknown keys and values of the wrong type
+func ValidateFile() []error {
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return []error{fmt.Errorf("failed to read %s: %w", configFile, err)}
+	}
+
+	var errs []error
+	for _, name := range v.AllKeys() {
+		k, err := LookupKey(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if k.Type == TypeList {
+			// Items such as patterns may contain commas, so they are not
+			// checked in their comma separated form
+			if k.check != nil {
+				if err := k.check(v.GetStringSlice(name)); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", k.Name, err))
+				}
+			}
+			continue
+		}
+		if _, err := k.Parse(FormatValue(v.Get(name))); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for name, value := range defaultValues() {
+		v.SetDefault(name, value)
+	}
+	c := &Config{}
+	if err := v.Unmarshal(c); err != nil {
+		return []error{err}
+	}
+	return c.Validate()
+}
+
+// Set parses, validates and saves one or more settings atomically
+func Set(values map[string]string) error {
+	parsed := map[string]interface{}{}
+	for name, raw := range values {
+		k, err := LookupKey(name)
+		if err != nil {
+			return err
+		}
+		value, err := k.Parse(raw)
+		if err != nil {
+			return err
+		}
+		parsed[name] = value
+	}
+	return save(parsed)
+}
+
+// Unset restores one or more settings to their defaults
+func Unset(names ...string) error {
+	parsed := map[string]interface{}{}
+	for _, name := range names {
+		k, err := LookupKey(name)
+		if err != nil {
+			return err
+		}
+		parsed[name] = k.Default()
+	}
+	return save(parsed)
+}
+
+// save applies the new values, validates the resulting config and writes it
+// to disk. Nothing is written if validation fails.
+func save(values map[string]interface{}) error {
+	previous := map[string]interface{}{}
+	for name, value := range values {
+		previous[name] = viper.Get(name)
+		viper.Set(name, value)
+	}
+
+	candidate := &Config{}
+	err := viper.Unmarshal(candidate)
+	if err == nil {
+		if errs := candidate.Validate(); len(errs) > 0 {
+			err = errors.Join(errs...)
+		}
+	}
+	if err == nil {
+		err = viper.WriteConfigAs(configFile)
+	}
+	if err != nil {
+		for name, value := range previous {
+			viper.Set(name, value)
+		}
+		return err
+	}
+
+	cfg = candidate
+	if path := os.Getenv(EnvDatabasePath); path != "" {
+		cfg.DatabasePath = path
+	}
+	cfg.DatabasePath = ExpandPath(cfg.DatabasePath)
+	return nil
+}
+
+func nonEmpty(value interface{}) error {
+	if s, _ := value.(string); strings.TrimSpace(s) == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func intRange(min, max int) func(interface{}) error {
+	return func(value interface{}) error {
+		if n, _ := value.(int); n < min || n > max {
+			return fmt.Errorf("must be between %d and %d, got %v", min, max, value)
+		}
+		return nil
+	}
+}
+
+func floatRange(min, max float64) func(interface{}) error {
+	return func(value interface{}) error {
+		if f, _ := value.(float64); f < min || f > max || math.IsNaN(f) {
+			return fmt.Errorf("must be between %g and %g, got %v", min, max, value)
+		}
+		return nil
+	}
+}
+
+// names checks a list of command names
+func names(value interface{}) error {
+	list, _ := value.([]string)
+	for _, name := range list {
+		if strings.ContainsAny(name, " \t/") {
+			return fmt.Errorf("%q is not a command name", name)
+		}
+	}
+	return nil
+}
+
+func patterns(value interface{}) error {
+	list, _ := value.([]string)
+	for _, p := range list {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// ignorePatterns checks the regular expressions among ignore patterns;
+// every glob is valid
+func ignorePatterns(value interface{}) error {
+	list, _ := value.([]string)
+	for _, p := range list {
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func oneOf(options ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		s, _ := value.(string)
+		for _, o := range options {
+			if s == o {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(options, ", "), s)
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// loadTemp loads the config from a temporary directory, with viper's
+// overrides from earlier tests cleared
+func loadTemp(t *testing.T) string {
+	t.Helper()
+	viper.Reset()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(EnvDatabasePath, "")
+	path := filepath.Join(dir, "config.yaml")
+	t.Setenv(EnvConfigPath, path)
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeysMatchConfig(t *testing.T) {
+	values := Default().values()
+	for _, k := range Keys() {
+		if _, ok := values[k.Name]; !ok {
+			t.Errorf("%s has no Config field in values()", k.Name)
+		}
+		if k.Description == "" {
+			t.Errorf("%s has no description", k.Name)
+		}
+		delete(values, k.Name)
+	}
+	for name := range values {
+		t.Errorf("values() has %s, which is not a key", name)
+	}
+	if errs := Default().Validate(); len(errs) > 0 {
+		t.Errorf("the defaults do not validate: %v", errs)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		key, raw string
+		want     interface{}
+		err      string
+	}{
+		{key: "max_suggestions", raw: "7", want: 7},
+		{key: "max_suggestions", raw: "seven", err: `expected int, got "seven"`},
+		{key: "max_suggestions", raw: "1.5", err: "expected int"},
+		{key: "max_suggestions", raw: "0", err: "max_suggestions:"},
+		{key: "recency_weight", raw: "0.25", want: 0.25},
+		{key: "recency_weight", raw: "heavy", err: "expected float"},
+		{key: "recency_weight", raw: "2", err: "recency_weight:"},
+		{key: "enable_colors", raw: "false", want: false},
+		{key: "enable_colors", raw: "maybe", err: "expected bool"},
+		{key: "database_path", raw: " ", err: "must not be empty"},
+		// Lists are comma separated, blank items dropped
+		{key: "wrappers", raw: "sudo, doas,,env ", want: []string{"sudo", "doas", "env"}},
+		{key: "ignore_patterns", raw: "", want: []string{}},
+		{key: "ignore_patterns", raw: "re:^vim? ,git commit -m *", want: []string{"re:^vim?", "git commit -m *"}},
+		{key: "ignore_patterns", raw: "re:(", err: "ignore_patterns:"},
+	} {
+		k, err := LookupKey(tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := k.Parse(tt.raw)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse %s=%q error = %v, want one containing %q", tt.key, tt.raw, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse %s=%q = %#v, %v, want %#v", tt.key, tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestLookupUnknownKey(t *testing.T) {
+	if _, err := LookupKey("max_sugestions"); err == nil || !strings.Contains(err.Error(), "unknown config key") {
+		t.Errorf("LookupKey of a typo = %v, want an unknown key error", err)
+	}
+}
+
+func TestSetAndUnset(t *testing.T) {
+	path := loadTemp(t)
+
+	if err := Set(map[string]string{"max_suggestions": "7", "wrappers": "sudo,env"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := Get(); c.MaxSuggestions != 7 || !reflect.DeepEqual(c.Wrappers, []string{"sudo", "env"}) {
+		t.Errorf("after Set: max_suggestions %d, wrappers %v", c.MaxSuggestions, c.Wrappers)
+	}
+
+	// Nothing is saved if any value is unknown, mistyped or breaks a rule
+	// spanning keys
+	for _, bad := range []map[string]string{
+		{"max_suggestions": "9", "colour": "true"},
+		{"max_suggestions": "9", "busy_timeout_ms": "soon"},
+		{"ignore_min_length": "50", "ignore_max_length": "10"},
+	} {
+		if err := Set(bad); err == nil {
+			t.Errorf("Set(%v) succeeded", bad)
+		}
+	}
+	if c := Get(); c.MaxSuggestions != 7 || c.IgnoreMinLength != Default().IgnoreMinLength {
+		t.Errorf("a failed Set changed the config: %+v", c)
+	}
+
+	// The file holds the values, lists as YAML sequences
+	viper.Reset()
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxSuggestions != 7 || !reflect.DeepEqual(c.Wrappers, []string{"sudo", "env"}) {
+		t.Errorf("reloaded %s: max_suggestions %d, wrappers %v", path, c.MaxSuggestions, c.Wrappers)
+	}
+
+	if err := Unset("max_suggestions", "wrappers"); err != nil {
+		t.Fatal(err)
+	}
+	if c := Get(); c.MaxSuggestions != Default().MaxSuggestions || !reflect.DeepEqual(c.Wrappers, Default().Wrappers) {
+		t.Errorf("after Unset: max_suggestions %d, wrappers %v", c.MaxSuggestions, c.Wrappers)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	path := loadTemp(t)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// List items may contain commas
+	write("max_suggestions: 5\nignore_patterns:\n  - 're:^(vim|nano),'\n  - git commit -m *\n")
+	if errs := ValidateFile(); len(errs) > 0 {
+		t.Errorf("valid file: %v", errs)
+	}
+
+	write("max_suggestions: lots\nmax_sugestions: 5\nenable_colors: maybe\nignore_patterns: ['re:(']\n")
+	var got []string
+	for _, err := range ValidateFile() {
+		got = append(got, err.Error())
+	}
+	for _, want := range []string{`max_suggestions: expected int, got "lots"`, `unknown config key "max_sugestions"`,
+		`enable_colors: expected bool, got "maybe"`, "ignore_patterns:"} {
+		found := false
+		for _, err := range got {
+			found = found || strings.Contains(err, want)
+		}
+		if !found {
+			t.Errorf("ValidateFile = %q, want an error containing %q", got, want)
+		}
+	}
+}
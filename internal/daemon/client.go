@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+)
+
+const (
+	dialTimeout = 50 * time.Millisecond
+	callTimeout = 2 * time.Second
+)
+
+// ErrNotRunning is returned when no daemon is listening on the socket
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Call sends one request to the daemon and waits for the response. It
+// returns ErrNotRunning if the daemon cannot be reached, so callers can fall
+// back to opening the database directly.
+func Call(req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Running reports whether a daemon answers on the socket
+func Running() bool {
+	_, err := Call(Request{Op: OpPing})
+	return err == nil
+}
+
+// Track records a command run through the daemon and returns the command as
+// stored
+func Track(cmd string, e tracker.Execution) (string, error) {
+	e = e.Resolve()
+	resp, err := Call(Request{
+		Op:         OpTrack,
+		Command:    cmd,
+		Success:    e.Success,
+		ExitCode:   e.ExitCode,
+		DurationMs: e.Duration.Milliseconds(),
+		SessionID:  e.SessionID,
+		TTY:        e.TTY,
+		Hostname:   e.Hostname,
+		Directory:  e.Directory,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.FullCommand, nil
+}
+
+// Suggest returns ranked suggestions for a query
+func Suggest(rq db.RankQuery) ([]string, error) {
+	resp, err := Call(Request{
+		Op:            OpSuggest,
+		Query:         rq.Partial,
+		Directory:     rq.Directory,
+		SessionID:     rq.SessionID,
+		Limit:         rq.Limit,
+		IncludeFailed: rq.IncludeFailed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Commands, nil
+}
+
+// Search returns commands matching keywords
+func Search(keywords string, limit int) ([]string, error) {
+	resp, err := Call(Request{Op: OpSearch, Query: keywords, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Commands, nil
+}
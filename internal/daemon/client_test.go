@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+)
+
+func TestCallNotRunning(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Call(Request{Op: OpPing}); err != ErrNotRunning {
+		t.Errorf("Call without a daemon = %v, want ErrNotRunning", err)
+	}
+	if Running() {
+		t.Error("Running without a daemon")
+	}
+}
+
+func TestClient(t *testing.T) {
+	startServer(t)
+	if !Running() {
+		t.Fatal("daemon does not answer")
+	}
+
+	dir := t.TempDir()
+	for _, cmd := range []string{"docker build -t api .", "docker ps", "docker build -t api ."} {
+		if _, err := Track(cmd, tracker.Execution{Success: true, Directory: dir, SessionID: "s1"}); err != nil {
+			t.Fatalf("Track(%q): %v", cmd, err)
+		}
+	}
+	// Errors of the daemon come back as errors of the call
+	if _, err := Track("ls", tracker.Execution{Success: true, Directory: dir}); err == nil || err == ErrNotRunning {
+		t.Errorf("Track of an ignored command = %v, want the daemon's error", err)
+	}
+
+	got, err := Suggest(db.RankQuery{Partial: "docker", Directory: dir, SessionID: "s1", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "docker build -t api ." {
+		t.Errorf("Suggest = %v, want docker build first", got)
+	}
+
+	// Commands sharing a template are suggested once, as in the picker
+	for _, cmd := range []string{"ssh deploy@10.0.0.12", "ssh deploy@10.0.0.13"} {
+		if _, err := Track(cmd, tracker.Execution{Success: true, Directory: dir}); err != nil {
+			t.Fatalf("Track(%q): %v", cmd, err)
+		}
+	}
+	got, err = Suggest(db.RankQuery{Partial: "ssh", Directory: dir, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "ssh deploy@10.0.0.13" {
+		t.Errorf("Suggest = %v, want the latest ssh command alone, then the others", got)
+	}
+
+	got, err = Search("ps", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "docker ps" {
+		t.Errorf("Search = %v, want docker ps", got)
+	}
+}
+
+func TestCallBrokenDaemon(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A daemon that answers with something other than a JSON line
+	listener, err := net.Listen("unix", SocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("not json\n"))
+			conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	if _, err := Call(Request{Op: OpPing}); err == nil || err == ErrNotRunning {
+		t.Errorf("Call to a broken daemon = %v, want a response error", err)
+	}
+	if time.Since(start) > callTimeout {
+		t.Error("Call outlived its timeout")
+	}
+}
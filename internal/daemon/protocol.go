@@ -0,0 +1,63 @@
+// Package daemon implements a long-running kwik-cmd process that keeps one
+// database connection open and serves track, suggest and search requests
+// over a Unix socket.
+//
+// The protocol is JSON lines: the client writes one Request object per line
+// and reads one Response object per line. A connection may carry any number
+// of requests.
+package daemon
+
+import (
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+// Operations understood by the daemon
+const (
+	OpPing     = "ping"
+	OpTrack    = "track"
+	OpSuggest  = "suggest"
+	OpSearch   = "search"
+	OpShutdown = "shutdown"
+)
+
+// Request is a single call to the daemon
+type Request struct {
+	Op string `json:"op"`
+
+	// track
+	Command    string `json:"command,omitempty"`
+	Success    bool   `json:"success,omitempty"`
+	ExitCode   int    `json:"exit_code,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	TTY        string `json:"tty,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+
+	// track and suggest
+	Directory string `json:"directory,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+
+	// suggest and search
+	Query string `json:"query,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+
+	// suggest
+	IncludeFailed bool `json:"include_failed,omitempty"`
+}
+
+// Response is the daemon's answer to a Request
+type Response struct {
+	OK       bool     `json:"ok"`
+	Error    string   `json:"error,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	// FullCommand is the command as stored by a track request
+	FullCommand string `json:"full_command,omitempty"`
+	// PID and Database are reported by ping
+	PID      int    `json:"pid,omitempty"`
+	Database string `json:"database,omitempty"`
+}
+
+// SocketPath returns the socket for the configured database. Each database
+// gets its own daemon, so separate histories (--db) never share one.
+func SocketPath() string {
+	return config.Get().DatabasePath + ".sock"
+}
@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/suggester"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+)
+
+// Server owns the database connection and the listening socket
+type Server struct {
+	listener net.Listener
+	path     string
+	// mu serializes database access; SQLite allows a single writer and the
+	// request handlers are short
+	mu   sync.Mutex
+	done chan struct{}
+	once sync.Once
+}
+
+// Listen opens the database and binds the socket. It fails if another
+// daemon is already serving the same database.
+func Listen() (*Server, error) {
+	path := SocketPath()
+
+	if Running() {
+		return nil, fmt.Errorf("daemon already running on %s", path)
+	}
+	// A socket file with nobody listening is left over from a crash
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	if err := db.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to secure socket: %w", err)
+	}
+
+	return &Server{listener: listener, path: path, done: make(chan struct{})}, nil
+}
+
+// Path returns the socket the server listens on
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Serve accepts connections until Shutdown is called or the process receives
+// SIGINT/SIGTERM
+func (s *Server) Serve() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			s.Shutdown()
+		case <-s.done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				wg.Wait()
+				return nil
+			default:
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			s.Shutdown()
+			wg.Wait()
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, removes the socket and closes the
+// database
+func (s *Server) Shutdown() {
+	s.once.Do(func() {
+		close(s.done)
+		s.listener.Close()
+		os.Remove(s.path)
+		s.mu.Lock()
+		db.Close()
+		s.mu.Unlock()
+	})
+}
+
+// handle serves every request on one connection
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		if !scanner.Scan() {
+			return
+		}
+
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = Response{Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp = s.dispatch(req)
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+		if req.Op == OpShutdown {
+			go s.Shutdown()
+			return
+		}
+	}
+}
+
+// dispatch runs a single request against the open database
+func (s *Server) dispatch(req Request) Response {
+	select {
+	case <-s.done:
+		return Response{Error: "daemon is shutting down"}
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case OpPing:
+		return Response{OK: true, PID: os.Getpid(), Database: db.Path()}
+
+	case OpTrack:
+		parsed, err := tracker.Record(req.Command, tracker.Execution{
+			Success:   req.Success,
+			ExitCode:  req.ExitCode,
+			Duration:  time.Duration(req.DurationMs) * time.Millisecond,
+			SessionID: req.SessionID,
+			TTY:       req.TTY,
+			Directory: req.Directory,
+			Hostname:  req.Hostname,
+		})
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{OK: true, FullCommand: parsed.FullCmd}
+
+	case OpSuggest:
+		commands, err := suggester.RankedPlain(db.RankQuery{
+			Partial:       req.Query,
+			Directory:     req.Directory,
+			SessionID:     req.SessionID,
+			Limit:         req.Limit,
+			IncludeFailed: req.IncludeFailed,
+		})
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{OK: true, Commands: commands}
+
+	case OpSearch:
+		commands, err := suggester.MatchesPlain(req.Query, req.Limit)
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{OK: true, Commands: commands}
+
+	case OpShutdown:
+		return Response{OK: true}
+
+	default:
+		return Response{Error: fmt.Sprintf("unknown op %q", req.Op)}
+	}
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+// startServer serves a database in a temporary directory until the test ends
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("KWIK_CMD_SESSION", "")
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+	t.Cleanup(func() {
+		s.Shutdown()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+func TestServerJSONLines(t *testing.T) {
+	s := startServer(t)
+	conn, err := net.Dial("unix", s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	responses := bufio.NewScanner(conn)
+
+	// One connection carries any number of requests, one JSON object per line
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		request string
+		check   func(Response) bool
+	}{
+		{`{"op":"ping"}`, func(r Response) bool { return r.OK && r.PID == os.Getpid() && r.Database != "" }},
+		{`{"op":"track","command":"git status","success":true,"directory":"` + dir + `"}`,
+			func(r Response) bool { return r.OK && r.FullCommand == "git status" }},
+		{`{"op":"suggest","query":"git","directory":"` + dir + `","limit":5}`,
+			func(r Response) bool { return r.OK && len(r.Commands) == 1 && r.Commands[0] == "git status" }},
+		{`{"op":"search","query":"status","limit":5}`,
+			func(r Response) bool { return r.OK && len(r.Commands) == 1 && r.Commands[0] == "git status" }},
+		{`{"op":"track","command":"cd ..","directory":"` + dir + `"}`,
+			func(r Response) bool { return !r.OK && strings.Contains(r.Error, "ignored") }},
+		{`{"op":"fly"}`, func(r Response) bool { return !r.OK && r.Error == `unknown op "fly"` }},
+		{`{"op":`, func(r Response) bool { return !r.OK && strings.HasPrefix(r.Error, "invalid request") }},
+		{`{"op":"ping"}`, func(r Response) bool { return r.OK }},
+	} {
+		if _, err := conn.Write([]byte(tc.request + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if !responses.Scan() {
+			t.Fatalf("no response to %s: %v", tc.request, responses.Err())
+		}
+		var resp Response
+		if err := json.Unmarshal(responses.Bytes(), &resp); err != nil {
+			t.Fatalf("response to %s is not JSON: %v", tc.request, err)
+		}
+		if !tc.check(resp) {
+			t.Errorf("%s: unexpected response %s", tc.request, responses.Bytes())
+		}
+	}
+}
+
+func TestServerShutdownRequest(t *testing.T) {
+	s := startServer(t)
+	resp, err := Call(Request{Op: OpShutdown})
+	if err != nil || !resp.OK {
+		t.Fatalf("shutdown = %+v, %v", resp, err)
+	}
+	<-s.done
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Error("socket was not removed")
+	}
+	if Running() {
+		t.Error("daemon still answers after shutdown")
+	}
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// ArgValue is a flag value or argument of a segment and the slot it fills,
+// e.g. prod for -n of "kubectl get pods" (see parser.ArgValue)
+type ArgValue struct {
+	Base       string
+	Subcommand string
+	// Flag is the flag the value was given with, "" for an argument
+	Flag string
+	// Position is the index of an argument, 0 for a flag value
+	Position int
+	Value    string
+}
+
+func addArgValue(q execer, commandID int64, v ArgValue) error {
+	_, err := q.Exec(`
+		INSERT OR IGNORE INTO command_args (command_id, base, subcommand, flag, position, value)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, commandID, v.Base, v.Subcommand, v.Flag, v.Position, v.Value)
+	return err
+}
+
+// ArgQuery describes the slot to complete a value for
+type ArgQuery struct {
+	Base       string
+	Subcommand string
+	// Flags are the flags whose values fill the slot, e.g. -n and
+	// --namespace; none for the argument at Position of Subcommand
+	Flags    []string
+	Position int
+	// Prefix is the part of the value typed so far
+	Prefix string
+	// Directory and Project are where the value is typed (see RankQuery)
+	Directory string
+	Project   string
+	Limit     int
+}
+
+// ArgCompletion is a value used in a slot before
+type ArgCompletion struct {
+	Value string
+	// RunCount is the number of runs of the commands the value was given to
+	RunCount int
+	LastUsed time.Time
+	Frecency float64
+	// FailureRate is the share of recent runs with the value that failed,
+	// weighted like frecency
+	FailureRate float64
+	Score       float64
+
+	directory float64
+}
+
+// sameSubcommandScore is the bonus for a flag value used with the
+// subcommand being typed, e.g. a namespace of "kubectl logs" when
+// completing "kubectl logs -n "
+const sameSubcommandScore = 0.1
+
+// CompleteArg returns the values used in a slot before, best first. Values
+// are ranked like commands, by the recency and frecency of the commands
+// they were given to, by where those ran and by how often they failed, with
+// the recency_weight, frequency_weight, directory_weight and failure_weight
+// of config. Frequency is relative to the busiest value of the slot. Values
+// only given to commands that never succeeded are left out.
+func CompleteArg(aq ArgQuery) ([]ArgCompletion, error) {
+	now := time.Now()
+	r, err := newRanker(now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT a.value, a.subcommand, c.frequency, c.last_used, c.directory, c.project, c.frecency, c.failure_frecency
+		FROM command_args a JOIN commands c ON c.id = a.command_id
+		WHERE a.base = ? AND substr(a.value, 1, length(?)) = ?
+			AND NOT (c.frequency > 0 AND c.successes = 0)`
+	args := []interface{}{aq.Base, aq.Prefix, aq.Prefix}
+	if len(aq.Flags) > 0 {
+		// Values of a flag are shared by every subcommand
+		query += " AND a.flag IN " + placeholders(len(aq.Flags))
+		for _, f := range aq.Flags {
+			args = append(args, f)
+		}
+	} else {
+		query += " AND a.flag = '' AND a.subcommand = ? AND a.position = ?"
+		args = append(args, aq.Subcommand, aq.Position)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[string]*ArgCompletion{}
+	sameSubcommand := map[string]bool{}
+	rq := RankQuery{Directory: aq.Directory, Project: aq.Project}
+	for rows.Next() {
+		var value, subcommand string
+		var c scannedCommand
+		var failures sql.NullFloat64
+		if err := rows.Scan(&value, &subcommand, &c.Frequency, &c.LastUsed,
+			&c.Directory, &c.Project, &c.stored, &failures); err != nil {
+			return nil, err
+		}
+		v := values[value]
+		if v == nil {
+			v = &ArgCompletion{Value: value}
+			values[value] = v
+		}
+		v.RunCount += c.Frequency
+		frecency := frecencyAt(c.stored, now, r.halfLifeDays)
+		v.Frecency += frecency
+		v.FailureRate += failureRate(c.stored, failures) * frecency
+		if c.LastUsed.After(v.LastUsed) {
+			v.LastUsed = c.LastUsed
+		}
+		v.directory = math.Max(v.directory, directoryScore(c.RankedCommand, rq))
+		if subcommand == aq.Subcommand {
+			sameSubcommand[value] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var top float64
+	for _, v := range values {
+		top = math.Max(top, v.Frecency)
+	}
+	completions := make([]ArgCompletion, 0, len(values))
+	for _, v := range values {
+		if v.Frecency > 0 {
+			v.FailureRate /= v.Frecency
+		}
+		v.Score = r.recencyWeight*math.Min(1, decay(v.LastUsed, now, r.halfLifeDays)) +
+			r.directoryWeight*v.directory - r.failureWeight*v.FailureRate
+		if top > 0 {
+			v.Score += r.frequencyWeight * math.Log1p(v.Frecency) / math.Log1p(top)
+		}
+		if len(aq.Flags) > 0 && sameSubcommand[v.Value] {
+			v.Score += sameSubcommandScore
+		}
+		completions = append(completions, *v)
+	}
+
+	sort.Slice(completions, func(i, j int) bool {
+		if completions[i].Score != completions[j].Score {
+			return completions[i].Score > completions[j].Score
+		}
+		return completions[i].Value < completions[j].Value
+	})
+	if aq.Limit > 0 && len(completions) > aq.Limit {
+		completions = completions[:aq.Limit]
+	}
+	return completions, nil
+}
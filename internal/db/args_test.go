@@ -0,0 +1,65 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// recordArgs stores a run of a kubectl command with its argument values
+func recordArgs(t *testing.T, fullCommand, directory string, success bool, at time.Time, values ...ArgValue) {
+	t.Helper()
+	for i := range values {
+		values[i].Base = "kubectl"
+	}
+	if _, err := RecordRun(Run{
+		Base:        "kubectl",
+		FullCommand: fullCommand,
+		Directory:   directory,
+		ArgValues:   values,
+		Execution:   Execution{Success: success, ExecutedAt: at},
+	}); err != nil {
+		t.Fatalf("record %s: %v", fullCommand, err)
+	}
+}
+
+func TestCompleteArg(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		recordArgs(t, "kubectl get pods -n prod", "/srv", true, now.Add(-48*time.Hour),
+			ArgValue{Subcommand: "get pods", Flag: "-n", Value: "prod"})
+	}
+	recordArgs(t, "kubectl logs web-1 --namespace=dev", "/repo", true, now.Add(-time.Hour),
+		ArgValue{Subcommand: "logs", Flag: "--namespace", Value: "dev"},
+		ArgValue{Subcommand: "logs", Value: "web-1"})
+	recordArgs(t, "kubectl get pods -n typo", "/repo", false, now,
+		ArgValue{Subcommand: "get pods", Flag: "-n", Value: "typo"})
+
+	complete := func(aq ArgQuery) []string {
+		t.Helper()
+		values, err := CompleteArg(aq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range values {
+			got = append(got, v.Value)
+		}
+		return got
+	}
+
+	// Values of aliases count, a value that only failed does not, and the
+	// current directory wins over a few more runs elsewhere
+	got := complete(ArgQuery{Base: "kubectl", Flags: []string{"-n", "--namespace"}, Directory: "/repo"})
+	assertOrder(t, got, "dev", "prod")
+
+	if got := complete(ArgQuery{Base: "kubectl", Flags: []string{"-n", "--namespace"}, Prefix: "p"}); len(got) != 1 || got[0] != "prod" {
+		t.Errorf("namespaces starting with p = %v", got)
+	}
+	if got := complete(ArgQuery{Base: "kubectl", Subcommand: "logs"}); len(got) != 1 || got[0] != "web-1" {
+		t.Errorf("pods of kubectl logs = %v", got)
+	}
+	if got := complete(ArgQuery{Base: "kubectl", Subcommand: "logs", Position: 1}); len(got) != 0 {
+		t.Errorf("second arguments of kubectl logs = %v", got)
+	}
+}
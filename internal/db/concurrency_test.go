@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+)
+
+// trackEnv makes TestTrackProcess track a command
+const trackEnv = "KWIK_CMD_TEST_TRACK"
+
+// TestTrackProcess is run by TestConcurrentTracking in child processes;
+// it does nothing in a normal test run
+func TestTrackProcess(t *testing.T) {
+	cmd := os.Getenv(trackEnv)
+	if cmd == "" {
+		return
+	}
+	if err := tracker.TrackCommandWithStatus(cmd, true, 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// TestConcurrentTracking runs many processes tracking the same command at
+// once against one database file, as shell hooks of several terminals do
+func TestConcurrentTracking(t *testing.T) {
+	const processes = 24
+	const command = "make test"
+
+	dir := t.TempDir()
+	env := append(os.Environ(),
+		"HOME="+dir,
+		config.EnvConfigPath+"="+filepath.Join(dir, "config.yaml"),
+		config.EnvDatabasePath+"="+filepath.Join(dir, "commands.db"),
+		trackEnv+"="+command,
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run := exec.Command(os.Args[0], "-test.run=^TestTrackProcess$")
+			run.Env = env
+			run.Dir = dir
+			if out, err := run.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("%v: %s", err, out)
+			} else if strings.Contains(string(out), "database is locked") {
+				errs <- fmt.Errorf("%s", out)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	t.Setenv("HOME", dir)
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var rows, frequency int
+	if err := db.GetDB().QueryRow("SELECT COUNT(*), COALESCE(SUM(frequency), 0) FROM commands WHERE full_command = ?",
+		command).Scan(&rows, &frequency); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 || frequency != processes {
+		t.Errorf("got %d rows with frequency %d, want 1 row with frequency %d", rows, frequency, processes)
+	}
+}
@@ -6,82 +6,68 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	_ "modernc.org/sqlite"
 )
 
-var db *sql.DB
+var (
+	db     *sql.DB
+	dbPath string
+)
 
+// Init opens the database and applies any pending schema migrations
 func Init() error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+	if err := Open(); err != nil {
+		return err
+	}
+
+	if _, err := Migrate(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := syncFrecency(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to update frecency: %w", err)
 	}
 
-	dataDir := filepath.Join(homeDir, ".kwik-cmd")
+	return nil
+}
+
+// Open opens the database configured by config.DatabasePath without
+// touching the schema
+func Open() error {
+	dbPath = config.Get().DatabasePath
+
+	dataDir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	dbPath := filepath.Join(dataDir, "commands.db")
-	db, err = sql.Open("sqlite3", dbPath)
+	// The busy timeout makes concurrent writers wait instead of failing with
+	// "database is locked", and immediate transactions take the write lock up
+	// front so they cannot deadlock upgrading from a read lock. WAL mode is
+	// set once by Migrate (see useWAL). Times are written in SQLite's own
+	// format so date functions work on them.
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)"+
+		"&_pragma=foreign_keys(1)&_txlock=immediate&_time_format=sqlite",
+		dbPath, config.Get().BusyTimeoutMs)
+
+	var err error
+	db, err = sql.Open("sqlite", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
-
 	return nil
 }
 
-func createTables() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS commands (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		base TEXT NOT NULL,
-		subcommand TEXT,
-		full_command TEXT NOT NULL,
-		frequency INTEGER DEFAULT 1,
-		last_used DATETIME DEFAULT CURRENT_TIMESTAMP,
-		directory TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS flags (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		command_id INTEGER NOT NULL,
-		flag TEXT NOT NULL,
-		meaning TEXT,
-		FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS keywords (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		command_id INTEGER NOT NULL,
-		keyword TEXT NOT NULL,
-		FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS usage_stats (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		command_id INTEGER NOT NULL,
-		success BOOLEAN DEFAULT TRUE,
-		exit_code INTEGER,
-		used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_commands_base ON commands(base);
-	CREATE INDEX IF NOT EXISTS idx_commands_directory ON commands(directory);
-	CREATE INDEX IF NOT EXISTS idx_keywords_keyword ON keywords(keyword);
-	CREATE INDEX IF NOT EXISTS idx_usage_stats_used_at ON usage_stats(used_at);
-	`
-
-	_, err := db.Exec(schema)
-	return err
+// Path returns the location of the open database file
+func Path() string {
+	return dbPath
 }
 
 func Close() {
@@ -98,44 +84,316 @@ type Command struct {
 	Frequency   int
 	LastUsed    time.Time
 	Directory   string
+	// Project identifies the project of Directory (see internal/project)
+	Project string
+	// Template is the command with the arguments that vary between runs
+	// replaced by {name} placeholders, or the command itself
+	Template string
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx so writes can run inside
+// or outside a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
+// AddCommand returns the id of the aggregate row for a command, creating it
+// if needed. Frequency and last_used are maintained by RecordExecution.
 func AddCommand(base, subcommand, fullCommand, directory string) (int64, error) {
-	// Check if command already exists
-	var existingID int64
-	err := db.QueryRow(`
+	return addCommand(db, base, subcommand, fullCommand, directory)
+}
+
+func addCommand(q execer, base, subcommand, fullCommand, directory string) (int64, error) {
+	// Counters start at zero until an execution is recorded. The UNIQUE index
+	// on (base, full_command, directory) makes concurrent inserts collapse
+	// into one row.
+	if _, err := q.Exec(`
+		INSERT INTO commands (base, subcommand, full_command, directory, frequency)
+		VALUES (?, ?, ?, ?, 0)
+		ON CONFLICT (base, full_command, directory) DO NOTHING
+	`, base, subcommand, fullCommand, directory); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := q.QueryRow(`
 		SELECT id FROM commands 
 		WHERE base = ? AND full_command = ? AND directory = ?
-	`, base, fullCommand, directory).Scan(&existingID)
+	`, base, fullCommand, directory).Scan(&id)
+	return id, err
+}
+
+// Flag is a flag seen on a command together with its meaning
+type Flag struct {
+	Flag    string
+	Meaning string
+}
+
+// Segment is one simple command of a pipeline or list, e.g. "tee log" in
+// "make build | tee log"
+type Segment struct {
+	Base        string
+	Subcommand  string
+	FullSegment string
+	// Op is the operator following the segment ("|", "&&", ...) or ""
+	Op string
+	// Wrapper lists the commands the segment ran under, e.g. "sudo timeout"
+	Wrapper string
+	// Template is FullSegment with placeholders, "" for FullSegment itself
+	Template string
+}
 
-	if err == nil {
-		// Update frequency and last_used
-		_, err = db.Exec(`
-			UPDATE commands 
-			SET frequency = frequency + 1, last_used = CURRENT_TIMESTAMP 
-			WHERE id = ?
-		`, existingID)
-		return existingID, err
+// Run is everything stored for one tracked command run
+type Run struct {
+	Base        string
+	Subcommand  string
+	FullCommand string
+	Directory   string
+	// Project is the identity of the project Directory belongs to, "" if
+	// it is not in one
+	Project string
+	// ProjectTypes are the kinds of project detected in Directory
+	ProjectTypes []string
+	Keywords     []string
+	Flags        []Flag
+	Segments     []Segment
+	// Args are the arguments of every segment, without flags
+	Args []string
+	// Template is FullCommand with placeholders (see Command.Template), ""
+	// for FullCommand itself
+	Template string
+	// ArgValues are the flag values and arguments of every segment, learned
+	// for completing them (see args.go)
+	ArgValues []ArgValue
+	Execution Execution
+}
+
+// RecordRun stores a command run - the command row, its segments, keywords
+// and flags and the execution event - in a single transaction and returns the
+// command id
+func RecordRun(run Run) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	commandID, err := addCommand(tx, run.Base, run.Subcommand, run.FullCommand, run.Directory)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add command: %w", err)
+	}
+	if _, err := setProject(tx, commandID, run.Project, run.ProjectTypes); err != nil {
+		return 0, fmt.Errorf("failed to set project: %w", err)
+	}
+
+	if err := addParse(tx, commandID, run); err != nil {
+		return 0, err
 	}
 
-	// Insert new command
-	result, err := db.Exec(`
-		INSERT INTO commands (base, subcommand, full_command, directory)
-		VALUES (?, ?, ?, ?)
-	`, base, subcommand, fullCommand, directory)
+	e := run.Execution
+	e.CommandID = commandID
+	e.FullCommand = run.FullCommand
+	e.Directory = run.Directory
+	e.ProjectTypes = run.ProjectTypes
+	if _, err := recordExecution(tx, e); err != nil {
+		return 0, fmt.Errorf("failed to record execution: %w", err)
+	}
+
+	return commandID, tx.Commit()
+}
 
+// addParse stores what parsing a command yields: its segments, keywords,
+// flags, arguments, argument values and template
+func addParse(q execer, commandID int64, run Run) error {
+	args := strings.Join(run.Args, " ")
+	template := run.Template
+	if template == "" {
+		template = run.FullCommand
+	}
+	if _, err := q.Exec("UPDATE commands SET args = ?, template = ? WHERE id = ? AND (args <> ? OR template <> ?)",
+		args, template, commandID, args, template); err != nil {
+		return fmt.Errorf("failed to set arguments: %w", err)
+	}
+
+	for i, seg := range run.Segments {
+		if err := addSegment(q, commandID, i, seg); err != nil {
+			return fmt.Errorf("failed to add segment %s: %w", seg.FullSegment, err)
+		}
+	}
+
+	for _, kw := range run.Keywords {
+		if err := addKeyword(q, commandID, kw); err != nil {
+			return fmt.Errorf("failed to add keyword %s: %w", kw, err)
+		}
+	}
+
+	for _, f := range run.Flags {
+		if err := addFlag(q, commandID, f.Flag, f.Meaning); err != nil {
+			return fmt.Errorf("failed to add flag %s: %w", f.Flag, err)
+		}
+	}
+
+	for _, v := range run.ArgValues {
+		if err := addArgValue(q, commandID, v); err != nil {
+			return fmt.Errorf("failed to add argument value %s: %w", v.Value, err)
+		}
+	}
+	return nil
+}
+
+// Reindex re-parses every stored command with parse and replaces its base,
+// subcommand, segments, keywords, flags, arguments, argument values and
+// template, e.g. after the parser learned to see through sudo. parse returns
+// false to leave a command untouched. It returns the number of commands
+// updated.
+func Reindex(parse func(fullCommand string) (Run, bool)) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type stored struct {
+		id          int64
+		fullCommand string
+	}
+	rows, err := tx.Query("SELECT id, full_command FROM commands ORDER BY id")
 	if err != nil {
 		return 0, err
 	}
+	var commands []stored
+	for rows.Next() {
+		var c stored
+		if err := rows.Scan(&c.id, &c.fullCommand); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		commands = append(commands, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
 
-	return result.LastInsertId()
+	updated := 0
+	for _, c := range commands {
+		run, ok := parse(c.fullCommand)
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE commands SET base = ?, subcommand = ? WHERE id = ?",
+			run.Base, run.Subcommand, c.id); err != nil {
+			return 0, fmt.Errorf("failed to update %s: %w", c.fullCommand, err)
+		}
+		if err := deleteParse(tx, c.id); err != nil {
+			return 0, err
+		}
+		if err := addParse(tx, c.id, run); err != nil {
+			return 0, err
+		}
+		updated++
+	}
+
+	return updated, tx.Commit()
 }
 
-func RecordUsage(commandID int64, success bool, exitCode int) error {
-	_, err := db.Exec(`
-		INSERT INTO usage_stats (command_id, success, exit_code)
-		VALUES (?, ?, ?)
-	`, commandID, success, exitCode)
+// ReindexProjects re-detects the project and project types of every
+// directory in the history with detect and returns the number of commands
+// whose project changed. Directories that no longer exist are left alone.
+func ReindexProjects(detect func(directory string) (project string, types []string, ok bool)) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type stored struct {
+		id        int64
+		directory string
+	}
+	rows, err := tx.Query("SELECT id, directory FROM commands WHERE directory IS NOT NULL AND directory <> '' ORDER BY directory")
+	if err != nil {
+		return 0, err
+	}
+	var commands []stored
+	for rows.Next() {
+		var c stored
+		if err := rows.Scan(&c.id, &c.directory); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		commands = append(commands, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	type detected struct {
+		project string
+		types   []string
+		ok      bool
+	}
+	directories := map[string]detected{}
+	updated := 0
+	for _, c := range commands {
+		d, seen := directories[c.directory]
+		if !seen {
+			d.project, d.types, d.ok = detect(c.directory)
+			directories[c.directory] = d
+		}
+		if !d.ok {
+			continue
+		}
+		changed, err := setProject(tx, c.id, d.project, d.types)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update %s: %w", c.directory, err)
+		}
+		if changed {
+			updated++
+		}
+	}
+
+	return updated, tx.Commit()
+}
+
+// setProject records the project and project types of a command's
+// directory; a directory can join a project later, e.g. by git init. It
+// reports whether the project changed.
+func setProject(q execer, commandID int64, project string, types []string) (bool, error) {
+	result, err := q.Exec("UPDATE commands SET project = ? WHERE id = ? AND project <> ?",
+		project, commandID, project)
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := q.Exec("DELETE FROM command_project_types WHERE command_id = ?", commandID); err != nil {
+		return false, err
+	}
+	for _, t := range types {
+		if _, err := q.Exec(`
+			INSERT OR IGNORE INTO command_project_types (command_id, project_type) VALUES (?, ?)
+		`, commandID, t); err != nil {
+			return false, err
+		}
+	}
+	return changed > 0, nil
+}
+
+func addSegment(q execer, commandID int64, position int, seg Segment) error {
+	template := seg.Template
+	if template == "" {
+		template = seg.FullSegment
+	}
+	_, err := q.Exec(`
+		INSERT OR IGNORE INTO command_segments (command_id, position, base, subcommand, full_segment, op, wrapper, template)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, commandID, position, seg.Base, seg.Subcommand, seg.FullSegment, seg.Op, seg.Wrapper, template)
 	return err
 }
 
@@ -185,38 +443,63 @@ func GetTopCommands(limit int) ([]Command, error) {
 	return commands, nil
 }
 
-func SearchByKeyword(keyword string) ([]Command, error) {
+// CommandHead is a base command with a subcommand path, e.g. "kubectl"
+// "get pods", and how many of its runs succeeded
+type CommandHead struct {
+	Base       string
+	Subcommand string
+	Successes  int
+}
+
+// SucceededHeads returns every base and subcommand that has succeeded at
+// least once, the vocabulary typos are corrected against
+func SucceededHeads() ([]CommandHead, error) {
 	rows, err := db.Query(`
-		SELECT DISTINCT c.id, c.base, c.subcommand, c.full_command, c.frequency, c.last_used, c.directory
-		FROM commands c
-		JOIN keywords k ON c.id = k.command_id
-		WHERE k.keyword LIKE ?
-		ORDER BY c.frequency DESC
-		LIMIT 20
-	`, "%"+keyword+"%")
+		SELECT base, COALESCE(subcommand, ''), SUM(successes) FROM commands
+		WHERE successes > 0
+		GROUP BY base, COALESCE(subcommand, '')
+	`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var commands []Command
+	var heads []CommandHead
 	for rows.Next() {
-		var c Command
-		if err := rows.Scan(&c.ID, &c.Base, &c.Subcommand, &c.FullCommand, &c.Frequency, &c.LastUsed, &c.Directory); err != nil {
+		var h CommandHead
+		if err := rows.Scan(&h.Base, &h.Subcommand, &h.Successes); err != nil {
 			return nil, err
 		}
-		commands = append(commands, c)
+		heads = append(heads, h)
 	}
-	return commands, nil
+	return heads, rows.Err()
 }
 
+// GetStats returns the number of distinct commands and the number of
+// recorded executions
 func GetStats() (totalCommands int, totalExecutions int, err error) {
-	err = db.QueryRow("SELECT COUNT(*), COALESCE(SUM(frequency), 0) FROM commands").Scan(&totalCommands, &totalExecutions)
+	err = db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM commands),
+			(SELECT COUNT(*) FROM executions)
+	`).Scan(&totalCommands, &totalExecutions)
 	return
 }
 
+// Reset deletes the whole history. Tables referencing commands are emptied
+// before it, since not all of them cascade.
 func Reset() error {
-	_, err := db.Exec("DELETE FROM usage_stats; DELETE FROM keywords; DELETE FROM flags; DELETE FROM commands;")
+	_, err := db.Exec(`
+		DELETE FROM executions;
+		DELETE FROM usage_stats;
+		DELETE FROM command_segments;
+		DELETE FROM keywords;
+		DELETE FROM flags;
+		DELETE FROM command_args;
+		DELETE FROM command_project_types;
+		DELETE FROM commands;
+		DELETE FROM transitions;
+	`)
 	return err
 }
 
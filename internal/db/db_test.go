@@ -0,0 +1,37 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResetPopulated(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	for _, run := range []Run{
+		{Base: "go", Subcommand: "test", FullCommand: "go test ./...", Directory: "/repo",
+			ProjectTypes: []string{"go"}, Flags: []Flag{{Flag: "-v"}}, Keywords: []string{"go", "test"}},
+		{Base: "kubectl", Subcommand: "get", FullCommand: "kubectl get pods -n prod", Directory: "/repo",
+			ArgValues: []ArgValue{{Base: "kubectl", Subcommand: "get pods", Flag: "-n", Value: "prod"}}},
+	} {
+		run.Segments = []Segment{{Base: run.Base, Subcommand: run.Subcommand, FullSegment: run.FullCommand}}
+		run.Execution = Execution{Success: true, ExecutedAt: now}
+		if _, err := RecordRun(run); err != nil {
+			t.Fatalf("record %s: %v", run.FullCommand, err)
+		}
+	}
+
+	if err := Reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	for _, table := range []string{"commands", "executions", "command_segments", "keywords", "flags",
+		"command_args", "command_project_types", "transitions", "usage_stats"} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after reset", table, n)
+		}
+	}
+}
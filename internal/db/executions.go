@@ -0,0 +1,210 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Execution is a single run of a command. The executions table is the
+// source of truth; the frequency and last_used columns on commands are
+// aggregates derived from it.
+type Execution struct {
+	ID          int64
+	CommandID   int64
+	FullCommand string
+	Directory   string
+	ExitCode    int
+	Success     bool
+	DurationMs  int64
+	SessionID   string
+	Hostname    string
+	TTY         string
+	// GitRoot, GitBranch and GitRemote describe the git repository the
+	// command ran in, if any (see internal/project)
+	GitRoot   string
+	GitBranch string
+	GitRemote string
+	// ProjectTypes are the kinds of project detected where the command ran
+	// (go, node, make, ...)
+	ProjectTypes []string
+	ExecutedAt   time.Time
+}
+
+// RecordExecution stores an execution event and refreshes the aggregates of
+// the command it belongs to
+func RecordExecution(e Execution) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := recordExecution(tx, e)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func recordExecution(q execer, e Execution) (int64, error) {
+	if e.ExecutedAt.IsZero() {
+		e.ExecutedAt = time.Now()
+	}
+
+	result, err := q.Exec(`
+		INSERT INTO executions (command_id, full_command, directory, exit_code, success,
+			duration_ms, session_id, hostname, tty, git_root, git_branch, git_remote, project_types,
+			executed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.CommandID, e.FullCommand, e.Directory, e.ExitCode, e.Success,
+		e.DurationMs, e.SessionID, e.Hostname, e.TTY, e.GitRoot, e.GitBranch, e.GitRemote,
+		strings.Join(e.ProjectTypes, ","), e.ExecutedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := refreshAggregates(q, e.CommandID); err != nil {
+		return 0, fmt.Errorf("failed to refresh aggregates: %w", err)
+	}
+	if err := addFrecency(q, e.CommandID, e.ExecutedAt, !e.Success); err != nil {
+		return 0, fmt.Errorf("failed to update frecency: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := recordTransition(q, id, e); err != nil {
+		return 0, fmt.Errorf("failed to record transition: %w", err)
+	}
+	return id, nil
+}
+
+// refreshAggregates recomputes frequency, successes and last_used for one
+// command
+func refreshAggregates(q execer, commandID int64) error {
+	_, err := q.Exec(`
+		UPDATE commands SET
+			frequency = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id),
+			successes = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id AND success),
+			last_used = COALESCE(
+				(SELECT MAX(executed_at) FROM executions WHERE command_id = commands.id),
+				last_used)
+		WHERE id = ?
+	`, commandID)
+	return err
+}
+
+// RebuildAggregates recomputes frequency, successes, last_used and frecency
+// for every command from the execution log, e.g. after importing or pruning
+// history
+func RebuildAggregates() error {
+	_, err := db.Exec(`
+		UPDATE commands SET
+			frequency = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id),
+			successes = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id AND success),
+			last_used = COALESCE(
+				(SELECT MAX(executed_at) FROM executions WHERE command_id = commands.id),
+				last_used)
+	`)
+	if err != nil {
+		return err
+	}
+
+	halfLife, err := storedHalfLife(db)
+	if err != nil {
+		return err
+	}
+	return RebuildFrecency(halfLife)
+}
+
+// ExecutionFilter narrows the executions returned by GetExecutions
+type ExecutionFilter struct {
+	SessionID string
+	Directory string
+	Since     time.Time
+	Limit     int
+}
+
+// GetExecutions returns executions newest first
+func GetExecutions(filter ExecutionFilter) ([]Execution, error) {
+	query := `
+		SELECT id, command_id, full_command, directory, exit_code, success,
+			duration_ms, session_id, hostname, tty, git_root, git_branch, git_remote, project_types,
+			executed_at
+		FROM executions
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.SessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, filter.SessionID)
+	}
+	if filter.Directory != "" {
+		query += " AND directory = ?"
+		args = append(args, filter.Directory)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND executed_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+
+	query += " ORDER BY executed_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var executions []Execution
+	for rows.Next() {
+		var e Execution
+		var directory sql.NullString
+		var projectTypes string
+		if err := rows.Scan(&e.ID, &e.CommandID, &e.FullCommand, &directory, &e.ExitCode, &e.Success,
+			&e.DurationMs, &e.SessionID, &e.Hostname, &e.TTY, &e.GitRoot, &e.GitBranch, &e.GitRemote,
+			&projectTypes, &e.ExecutedAt); err != nil {
+			return nil, err
+		}
+		e.Directory = directory.String
+		if projectTypes != "" {
+			e.ProjectTypes = strings.Split(projectTypes, ",")
+		}
+		executions = append(executions, e)
+	}
+	return executions, rows.Err()
+}
+
+// timestampLayouts are the formats SQLite and the driver use for DATETIME
+// values. Aggregates such as MAX() lose the column type, so they come back
+// as plain strings.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a DATETIME value returned as text
+func parseTimestamp(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
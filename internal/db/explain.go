@@ -0,0 +1,120 @@
+package db
+
+import "time"
+
+// Signal is one term of a command's ranking score
+type Signal struct {
+	// Name is the config key of the weight without "_weight" (recency,
+	// frequency, directory, project_type, prediction, failure) or the kind
+	// of match of what was typed (prefix, fuzzy)
+	Name string `json:"name"`
+	// Value is the strength of the signal, 0-1
+	Value float64 `json:"value"`
+	// Weight is what Value is multiplied by
+	Weight float64 `json:"weight"`
+	// Score is the contribution to the total, negative for a penalty
+	Score float64 `json:"score"`
+}
+
+// Explanation breaks down the ranking score of a command into its signals
+// and the inputs they were computed from, so weights can be tuned with
+// evidence
+type Explanation struct {
+	Score   float64  `json:"score"`
+	Signals []Signal `json:"signals"`
+
+	// LastUsed and HalfLifeDays give the recency signal
+	LastUsed     time.Time `json:"last_used"`
+	HalfLifeDays float64   `json:"half_life_days"`
+	// RunCount, Frecency and MaxFrecency, the frecency of the busiest
+	// command, give the frequency signal
+	RunCount    int     `json:"run_count"`
+	Frecency    float64 `json:"frecency"`
+	MaxFrecency float64 `json:"max_frecency"`
+	// Directory is where the command was last run and DirectoryMatch how it
+	// relates to the current directory: "directory", "project", "related"
+	// or "" for none
+	Directory      string `json:"directory"`
+	DirectoryMatch string `json:"directory_match"`
+	Project        string `json:"project,omitempty"`
+	// ProjectTypes are the kinds of project the command was run in
+	ProjectTypes []string `json:"project_types,omitempty"`
+	// Probability is the chance of the command following the session's
+	// last command
+	Probability float64 `json:"probability"`
+	// Successes and FailureRate give the failure penalty
+	Successes   int     `json:"successes"`
+	FailureRate float64 `json:"failure_rate"`
+	// Match is how what was typed matched: "prefix", "fuzzy" or ""
+	Match        string  `json:"match,omitempty"`
+	MatchQuality float64 `json:"match_quality,omitempty"`
+}
+
+// signals computes the terms of a command's score
+func (r *ranker) signals(c RankedCommand, rq RankQuery) []Signal {
+	prefix, fuzzy := 0.0, 0.0
+	if rq.Partial != "" {
+		if isPrefixMatch(c, rq.Partial) {
+			prefix = 1
+		} else {
+			fuzzy = c.matchQuality
+		}
+	}
+
+	signals := []Signal{
+		{Name: "recency", Value: r.recencyScore(c), Weight: r.recencyWeight},
+		{Name: "frequency", Value: r.frequencyScore(c), Weight: r.frequencyWeight},
+		{Name: "directory", Value: directoryScore(c, rq), Weight: r.directoryWeight},
+		{Name: "project_type", Value: projectTypeScore(c, rq), Weight: r.projectTypeWeight},
+		{Name: "prediction", Value: r.predicted[c.FullCommand], Weight: r.predictionWeight},
+		{Name: "prefix", Value: prefix, Weight: prefixMatchScore},
+		{Name: "fuzzy", Value: fuzzy, Weight: fuzzyMatchScore},
+		{Name: "failure", Value: c.FailureRate, Weight: r.failureWeight},
+	}
+	for i := range signals {
+		s := &signals[i]
+		s.Score = s.Value * s.Weight
+		if s.Name == "failure" && s.Score != 0 {
+			s.Score = -s.Score
+		}
+	}
+	return signals
+}
+
+// explain returns the explanation of a command's score
+func (r *ranker) explain(c RankedCommand, rq RankQuery) *Explanation {
+	e := &Explanation{
+		Signals:      r.signals(c, rq),
+		LastUsed:     c.LastUsed,
+		HalfLifeDays: r.halfLifeDays,
+		RunCount:     c.Frequency,
+		Frecency:     c.Frecency,
+		MaxFrecency:  r.maxFrecency,
+		Directory:    c.Directory,
+		Project:      c.Project,
+		ProjectTypes: c.ProjectTypes,
+		Probability:  r.predicted[c.FullCommand],
+		Successes:    c.Successes,
+		FailureRate:  c.FailureRate,
+	}
+	for _, s := range e.Signals {
+		e.Score += s.Score
+	}
+
+	switch directoryScore(c, rq) {
+	case sameDirectoryScore:
+		e.DirectoryMatch = "directory"
+	case sameProjectScore:
+		e.DirectoryMatch = "project"
+	case relatedDirectoryScore:
+		e.DirectoryMatch = "related"
+	}
+	if rq.Partial != "" {
+		if isPrefixMatch(c, rq.Partial) {
+			e.Match = "prefix"
+		} else if c.matchQuality > 0 {
+			e.Match, e.MatchQuality = "fuzzy", c.matchQuality
+		}
+	}
+	return e
+}
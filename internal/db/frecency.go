@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+// Frecency is the number of executions of a command weighted by age: each
+// run counts 1 when it happens and half as much after every half-life.
+//
+// The sum is kept per command in commands.frecency as a base-2 logarithm
+// relative to the Unix epoch, log2(sum of 2^(t/h)), so it never has to be
+// decayed in place: the current value is 2^(frecency - now/h), and ordering
+// by the stored column orders by current frecency. The half-life the column
+// was computed with is recorded in the meta table; it is rebuilt from the
+// executions whenever the configured half-life changes.
+//
+// commands.failure_frecency is the same sum over failed runs only, so the
+// recent failure rate of a command is 2^(failure_frecency - frecency).
+
+// metaHalfLife is the meta key holding the half-life (days) of the stored
+// frecency values
+const metaHalfLife = "frecency_half_life_days"
+
+// decayExponent returns t/h for a half-life in days, the log2 weight of an
+// execution at t relative to the epoch
+func decayExponent(t time.Time, halfLifeDays float64) float64 {
+	return float64(t.UnixNano()) / 1e9 / (halfLifeDays * 24 * 3600)
+}
+
+// logAdd2 returns log2(2^a + 2^b) without overflowing
+func logAdd2(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log2(1+math.Exp2(b-a))
+}
+
+// frecencyAt converts a stored frecency logarithm into the decayed execution
+// count at now
+func frecencyAt(stored sql.NullFloat64, now time.Time, halfLifeDays float64) float64 {
+	if !stored.Valid {
+		return 0
+	}
+	return math.Exp2(stored.Float64 - decayExponent(now, halfLifeDays))
+}
+
+// decay returns the weight of an event that happened at t, 1 at now and 0.5
+// one half-life earlier
+func decay(t, now time.Time, halfLifeDays float64) float64 {
+	return math.Exp2(decayExponent(t, halfLifeDays) - decayExponent(now, halfLifeDays))
+}
+
+// storedHalfLife returns the half-life the frecency column was computed with,
+// falling back to the configured one for a database not yet synced
+func storedHalfLife(q execer) (float64, error) {
+	value, ok, err := getMeta(q, metaHalfLife)
+	if err != nil || !ok {
+		return config.Get().FrecencyHalfLifeDays, err
+	}
+	days, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q in meta table", metaHalfLife, value)
+	}
+	return days, nil
+}
+
+// failureRate returns the share of a command's recent runs that failed
+func failureRate(stored, failures sql.NullFloat64) float64 {
+	if !stored.Valid || !failures.Valid {
+		return 0
+	}
+	return math.Min(1, math.Exp2(failures.Float64-stored.Float64))
+}
+
+// addFrecency adds one execution at t to a command's frecency, and to its
+// failure frecency if the run failed
+func addFrecency(q execer, commandID int64, t time.Time, failed bool) error {
+	halfLife, err := storedHalfLife(q)
+	if err != nil {
+		return err
+	}
+
+	var stored, failures sql.NullFloat64
+	if err := q.QueryRow("SELECT frecency, failure_frecency FROM commands WHERE id = ?", commandID).
+		Scan(&stored, &failures); err != nil {
+		return err
+	}
+
+	exponent := decayExponent(t, halfLife)
+	total := exponent
+	if stored.Valid {
+		total = logAdd2(stored.Float64, exponent)
+	}
+	if failed {
+		if failures.Valid {
+			exponent = logAdd2(failures.Float64, exponent)
+		}
+		failures = sql.NullFloat64{Float64: exponent, Valid: true}
+	}
+	_, err = q.Exec("UPDATE commands SET frecency = ?, failure_frecency = ? WHERE id = ?",
+		total, failures, commandID)
+	return err
+}
+
+// syncFrecency rebuilds the frecency column if it was computed with a
+// different half-life than the configured one
+func syncFrecency() error {
+	want := config.Get().FrecencyHalfLifeDays
+	value, ok, err := getMeta(db, metaHalfLife)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if have, err := strconv.ParseFloat(value, 64); err == nil && have == want {
+			return nil
+		}
+	}
+	return RebuildFrecency(want)
+}
+
+// RebuildFrecency recomputes every command's frecency and failure frecency
+// from the execution log using the given half-life
+func RebuildFrecency(halfLifeDays float64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT command_id, executed_at, success FROM executions")
+	if err != nil {
+		return err
+	}
+	sums := map[int64]float64{}
+	failures := map[int64]float64{}
+	for rows.Next() {
+		var id int64
+		var executedAt time.Time
+		var success bool
+		if err := rows.Scan(&id, &executedAt, &success); err != nil {
+			rows.Close()
+			return err
+		}
+		exponent := decayExponent(executedAt, halfLifeDays)
+		if sum, ok := sums[id]; ok {
+			sums[id] = logAdd2(sum, exponent)
+		} else {
+			sums[id] = exponent
+		}
+		if !success {
+			if sum, ok := failures[id]; ok {
+				exponent = logAdd2(sum, exponent)
+			}
+			failures[id] = exponent
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE commands SET frecency = NULL, failure_frecency = NULL"); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("UPDATE commands SET frecency = ?, failure_frecency = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, sum := range sums {
+		failed, ok := failures[id]
+		if _, err := stmt.Exec(sum, sql.NullFloat64{Float64: failed, Valid: ok}, id); err != nil {
+			return err
+		}
+	}
+
+	if err := setMeta(tx, metaHalfLife, strconv.FormatFloat(halfLifeDays, 'g', -1, 64)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func getMeta(q execer, key string) (string, bool, error) {
+	var value string
+	err := q.QueryRow("SELECT value FROM meta WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func setMeta(q execer, key, value string) error {
+	_, err := q.Exec(`
+		INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	return err
+}
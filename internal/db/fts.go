@@ -0,0 +1,325 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ftsWeights are the BM25 weights of the commands_fts columns: command,
+// args, keywords, flags, directory and note. A word of the command itself
+// or of a note the user wrote counts most.
+const ftsWeights = "4.0, 2.0, 2.0, 1.0, 1.0, 4.0"
+
+// TextQuery is a parsed search query (see ParseTextQuery)
+type TextQuery struct {
+	// Match is the FTS5 query over the text of commands, "" to match all
+	Match string
+	// Terms are the words and phrases searched for, for highlighting
+	Terms []string
+	// Plain is set for a query of bare words only, which can also be
+	// matched fuzzily
+	Plain bool
+
+	// Directory keeps commands run in it or below it
+	Directory string
+	// ExitCode keeps commands with a run that exited with it
+	ExitCode *int
+	// Failed keeps commands with a run that failed
+	Failed bool
+	// Since keeps commands with a run at or after it
+	Since time.Time
+}
+
+// queryToken is a word, a quoted phrase or a parenthesis of a query
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// ParseTextQuery parses a search query. Words must all appear (word* for a
+// prefix), "quoted phrases" must appear as written, AND, OR, NOT and
+// parentheses combine them, and qualifiers restrict where and how commands
+// ran. NOT excludes what follows it from what precedes it (a NOT b, or
+// a AND NOT b), so it cannot start a query or group.
+//
+//	dir:PATH    run in PATH or below it (~ and relative paths work)
+//	exit:N      a run exited with N; exit:ok and exit:fail for any success or failure
+//	since:WHEN  a run since WHEN: 3h, 2d, 1w, 90m or a date like 2024-01-31
+func ParseTextQuery(query string, now time.Time) (TextQuery, error) {
+	q := TextQuery{Plain: true}
+	var match []string
+	// FTS5 only joins plain phrases with an implicit AND, so it is written
+	// out after every operand that another follows
+	operand := false
+	depth := 0
+	add := func(expr string, opens, closes bool) {
+		if opens && operand {
+			match = append(match, "AND")
+		}
+		match = append(match, expr)
+		operand = closes
+	}
+	for _, tok := range tokenizeQuery(query) {
+		if !tok.quoted {
+			if name, value, ok := strings.Cut(tok.text, ":"); ok && value != "" {
+				handled, err := q.qualify(name, value, now)
+				if err != nil {
+					return TextQuery{}, err
+				}
+				if handled {
+					q.Plain = false
+					continue
+				}
+			}
+		}
+
+		switch {
+		case tok.quoted:
+			q.Plain = false
+			q.Terms = append(q.Terms, tok.text)
+			add(ftsString(tok.text), true, true)
+		case tok.text == "(":
+			q.Plain = false
+			depth++
+			add(tok.text, true, false)
+		case tok.text == ")":
+			q.Plain = false
+			if depth == 0 {
+				return TextQuery{}, fmt.Errorf("unbalanced parentheses: ) without (")
+			}
+			if last := match[len(match)-1]; last == "(" {
+				return TextQuery{}, fmt.Errorf("empty parentheses")
+			} else if !operand {
+				return TextQuery{}, fmt.Errorf("%s needs a term after it", last)
+			}
+			depth--
+			add(tok.text, false, true)
+		case tok.text == "NOT" && !operand && len(match) > 0 && match[len(match)-1] == "AND":
+			// FTS5's NOT is binary: a AND NOT b is written a NOT b
+			match[len(match)-1] = "NOT"
+		case tok.text == "AND" || tok.text == "OR" || tok.text == "NOT":
+			q.Plain = false
+			if !operand {
+				if len(match) == 0 || match[len(match)-1] == "(" {
+					return TextQuery{}, fmt.Errorf("%s needs a term before it (write a %s b)", tok.text, tok.text)
+				}
+				return TextQuery{}, fmt.Errorf("%s cannot follow %s", tok.text, match[len(match)-1])
+			}
+			add(tok.text, false, false)
+		case len(tok.text) > 1 && strings.HasSuffix(tok.text, "*"):
+			q.Plain = false
+			word := strings.TrimSuffix(tok.text, "*")
+			q.Terms = append(q.Terms, word)
+			add(ftsString(word)+"*", true, true)
+		default:
+			q.Terms = append(q.Terms, tok.text)
+			add(ftsString(tok.text), true, true)
+		}
+	}
+	if depth > 0 {
+		return TextQuery{}, fmt.Errorf("unbalanced parentheses: ( without )")
+	}
+	if len(match) > 0 && !operand {
+		return TextQuery{}, fmt.Errorf("%s needs a term after it", match[len(match)-1])
+	}
+	q.Match = strings.Join(match, " ")
+	return q, nil
+}
+
+// qualify applies a name:value qualifier and reports whether name is one
+func (q *TextQuery) qualify(name, value string, now time.Time) (bool, error) {
+	switch name {
+	case "dir":
+		if value == "~" || strings.HasPrefix(value, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return true, err
+			}
+			value = filepath.Join(home, strings.TrimPrefix(value, "~"))
+		}
+		dir, err := filepath.Abs(value)
+		if err != nil {
+			return true, fmt.Errorf("invalid dir: %w", err)
+		}
+		q.Directory = dir
+	case "exit":
+		switch value {
+		case "ok":
+			code := 0
+			q.ExitCode = &code
+		case "fail":
+			q.Failed = true
+		default:
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return true, fmt.Errorf("invalid exit %q: want a number, ok or fail", value)
+			}
+			q.ExitCode = &code
+		}
+	case "since":
+		since, err := parseSince(value, now)
+		if err != nil {
+			return true, err
+		}
+		q.Since = since
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// parseSince parses a date (2024-01-31), a number of days or weeks (2d, 1w)
+// or a Go duration (3h, 90m) before now
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n >= 0 {
+		switch value[len(value)-1] {
+		case 'd':
+			return now.AddDate(0, 0, -n), nil
+		case 'w':
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: want a duration like 3h, 2d or 1w, or a date like 2024-01-31", value)
+}
+
+// tokenizeQuery splits a query into words, "quoted phrases" and
+// parentheses. A quote inside a word (dir:"my dir") quotes part of it.
+func tokenizeQuery(query string) []queryToken {
+	var tokens []queryToken
+	var word strings.Builder
+	inWord, quoted, inQuote := false, false, false
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, queryToken{word.String(), quoted})
+		}
+		word.Reset()
+		inWord, quoted = false, false
+	}
+
+	for _, r := range query {
+		switch {
+		case inQuote:
+			if r == '"' {
+				inQuote = false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"':
+			if !inWord {
+				quoted = true
+			}
+			inWord, inQuote = true, true
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, queryToken{text: string(r)})
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// ftsString quotes s as an FTS5 string, which matches its words as a phrase
+func ftsString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// SearchText returns the commands matching a parsed query, best BM25 match
+// first, or most frecent first for a query of qualifiers only
+func SearchText(q TextQuery, limit int) ([]RankedCommand, error) {
+	now := time.Now()
+	halfLife, err := storedHalfLife(db)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + rankColumns + " FROM commands"
+	var args []interface{}
+	orderBy := "frecency DESC"
+	if q.Match != "" {
+		query += " JOIN (SELECT rowid AS fts_id, bm25(commands_fts, " + ftsWeights + ") AS fts_rank" +
+			" FROM commands_fts WHERE commands_fts MATCH ?) ON id = fts_id"
+		args = append(args, q.Match)
+		orderBy = "fts_rank, frecency DESC"
+	}
+	query += " WHERE 1 = 1"
+
+	if q.Directory != "" {
+		query += " AND (directory = ? OR directory LIKE ? ESCAPE '\\')"
+		args = append(args, q.Directory, escapeLike(strings.TrimSuffix(q.Directory, "/"))+"/%")
+	}
+	var runs []string
+	if q.ExitCode != nil {
+		runs = append(runs, "exit_code = ?")
+		args = append(args, *q.ExitCode)
+	}
+	if q.Failed {
+		runs = append(runs, "NOT success")
+	}
+	if !q.Since.IsZero() {
+		runs = append(runs, "julianday(executed_at) >= julianday(?)")
+		args = append(args, q.Since.UTC())
+	}
+	if len(runs) > 0 {
+		// The qualifiers on runs must hold for the same run
+		query += " AND id IN (SELECT command_id FROM executions WHERE " + strings.Join(runs, " AND ") + ")"
+	}
+
+	query += " ORDER BY " + orderBy + " LIMIT ?"
+	args = append(args, limit)
+
+	scanned, err := scanRanked(db.Query(query, args...))
+	if err != nil {
+		if q.Match != "" && strings.Contains(err.Error(), "fts5") {
+			return nil, fmt.Errorf("invalid search query %q: %w", q.Match, err)
+		}
+		return nil, err
+	}
+	commands := make([]RankedCommand, 0, len(scanned))
+	for _, c := range scanned {
+		c.Frecency = frecencyAt(c.stored, now, halfLife)
+		c.Score = c.Frecency
+		commands = append(commands, c.RankedCommand)
+	}
+	return commands, nil
+}
+
+// SetNote attaches a note to every stored copy of a command, one per
+// directory it ran in, and returns how many there are. Notes are searched
+// like the commands themselves; an empty note removes it.
+func SetNote(fullCommand, note string) (int, error) {
+	result, err := db.Exec("UPDATE commands SET note = ? WHERE full_command = ?", note, fullCommand)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetNote returns the note of a command, "" if it has none
+func GetNote(fullCommand string) (string, error) {
+	var note string
+	err := db.QueryRow(`
+		SELECT note FROM commands WHERE full_command = ? AND note <> '' LIMIT 1
+	`, fullCommand).Scan(&note)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return note, err
+}
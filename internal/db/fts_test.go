@@ -0,0 +1,142 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTextQuery(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		query, match string
+		plain        bool
+	}{
+		{"docker build", `"docker" AND "build"`, true},
+		{`"fix login" push`, `"fix login" AND "push"`, false},
+		{"(docker OR podman) NOT compose", `( "docker" OR "podman" ) NOT "compose"`, false},
+		{"(docker OR podman) build", `( "docker" OR "podman" ) AND "build"`, false},
+		{"dock* --force", `"dock"* AND "--force"`, false},
+		{"http://host:8080", `"http://host:8080"`, true},
+		{"git AND NOT checkout", `"git" NOT "checkout"`, false},
+		{"(git OR hg) AND NOT (checkout OR clone)", `( "git" OR "hg" ) NOT ( "checkout" OR "clone" )`, false},
+		{"(git) push", `( "git" ) AND "push"`, false},
+	} {
+		q, err := ParseTextQuery(tc.query, now)
+		if err != nil {
+			t.Fatalf("ParseTextQuery(%q): %v", tc.query, err)
+		}
+		if q.Match != tc.match || q.Plain != tc.plain {
+			t.Errorf("ParseTextQuery(%q) = %q plain=%v, want %q plain=%v", tc.query, q.Match, q.Plain, tc.match, tc.plain)
+		}
+	}
+
+	q, err := ParseTextQuery(`deploy dir:"/srv/my app" exit:fail since:2d`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Match != `"deploy"` || q.Directory != "/srv/my app" || !q.Failed || !q.Since.Equal(now.AddDate(0, 0, -2)) {
+		t.Errorf("qualifiers parsed as %+v", q)
+	}
+
+	for _, bad := range []string{
+		"exit:maybe", "since:soon",
+		// Operators need terms on both sides; FTS5's NOT is binary
+		"NOT checkout", "git OR", "git AND NOT", "OR git", "git OR NOT checkout",
+		"git AND OR hg", "(NOT checkout)", "git (OR hg)",
+		// Parentheses must balance
+		"(git", "git)", "(git OR (hg)", "()",
+	} {
+		if _, err := ParseTextQuery(bad, now); err == nil {
+			t.Errorf("ParseTextQuery(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+// searchText runs a query and returns the commands found, in order
+func searchText(t *testing.T, query string) []string {
+	t.Helper()
+	q, err := ParseTextQuery(query, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := SearchText(q, 10)
+	if err != nil {
+		t.Fatalf("search %q: %v", query, err)
+	}
+	commands := make([]string, len(found))
+	for i, c := range found {
+		commands[i] = c.FullCommand
+	}
+	return commands
+}
+
+func TestSearchText(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	runs := []struct {
+		command, dir string
+		exit         int
+		at           time.Time
+		flags        []Flag
+	}{
+		{"git push --force", "/src/api", 0, now, []Flag{{"--force", "force update"}}},
+		{"git push origin main", "/src/web", 1, now.Add(-72 * time.Hour), nil},
+		{"docker build -t api .", "/src/api/deploy", 0, now.Add(-time.Hour), nil},
+		{"podman build .", "/home", 0, now, nil},
+	}
+	for _, r := range runs {
+		if _, err := RecordRun(Run{
+			Base:        r.command,
+			FullCommand: r.command,
+			Directory:   r.dir,
+			Keywords:    []string{"kw-" + r.command[:3]},
+			Flags:       r.flags,
+			Execution:   Execution{ExitCode: r.exit, Success: r.exit == 0, ExecutedAt: r.at},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SetNote("podman build .", "rootless container image"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The word in the command itself outweighs the directory
+	if got := searchText(t, "api"); len(got) != 2 || got[0] != "docker build -t api ." {
+		t.Errorf("search api = %v, want docker build first", got)
+	}
+
+	for query, want := range map[string][]string{
+		"push":                     {"git push --force", "git push origin main"},
+		`"push origin"`:            {"git push origin main"},
+		"build NOT docker":         {"podman build ."},
+		"build AND NOT docker":     {"podman build ."},
+		"(docker OR podman) build": {"docker build -t api .", "podman build ."},
+		"pod*":                     {"podman build ."},
+		"rootless":                 {"podman build ."},
+		"update":                   {"git push --force"},
+		"kw":                       {"docker build -t api .", "git push --force", "git push origin main", "podman build ."},
+		"push dir:/src/api":        {"git push --force"},
+		"dir:/src/api":             {"git push --force", "docker build -t api ."},
+		"exit:fail":                {"git push origin main"},
+		"push exit:0":              {"git push --force"},
+		"push since:1d":            {"git push --force"},
+		"nothing-like-this":        {},
+	} {
+		got := searchText(t, query)
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("search %q = %v, want %v", query, got, want)
+		}
+	}
+
+	// Removing a command's keywords and flags updates the index
+	if _, err := db.Exec("DELETE FROM flags"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchText(t, "update"); len(got) != 0 {
+		t.Errorf("search update after deleting flags = %v, want none", got)
+	}
+}
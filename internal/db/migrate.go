@@ -0,0 +1,625 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// migration is a single, ordered schema change. Migrations are append-only:
+// once released, a migration must never be edited, only followed by a new one.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// execSQL returns a migration step that runs a fixed SQL script
+func execSQL(script string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(script)
+		return err
+	}
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		up: execSQL(`
+		CREATE TABLE IF NOT EXISTS commands (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			base TEXT NOT NULL,
+			subcommand TEXT,
+			full_command TEXT NOT NULL,
+			frequency INTEGER DEFAULT 1,
+			last_used DATETIME DEFAULT CURRENT_TIMESTAMP,
+			directory TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS flags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			flag TEXT NOT NULL,
+			meaning TEXT,
+			FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS keywords (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			keyword TEXT NOT NULL,
+			FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS usage_stats (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			success BOOLEAN DEFAULT TRUE,
+			exit_code INTEGER,
+			used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_commands_base ON commands(base);
+		CREATE INDEX IF NOT EXISTS idx_commands_directory ON commands(directory);
+		CREATE INDEX IF NOT EXISTS idx_keywords_keyword ON keywords(keyword);
+		CREATE INDEX IF NOT EXISTS idx_usage_stats_used_at ON usage_stats(used_at);
+		`),
+	},
+	{
+		version:     2,
+		description: "execution event log",
+		up: execSQL(`
+		CREATE TABLE IF NOT EXISTS executions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			full_command TEXT NOT NULL,
+			directory TEXT,
+			exit_code INTEGER DEFAULT 0,
+			success BOOLEAN DEFAULT TRUE,
+			duration_ms INTEGER DEFAULT 0,
+			session_id TEXT DEFAULT '',
+			hostname TEXT DEFAULT '',
+			tty TEXT DEFAULT '',
+			executed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_executions_command_id ON executions(command_id);
+		CREATE INDEX IF NOT EXISTS idx_executions_executed_at ON executions(executed_at);
+		CREATE INDEX IF NOT EXISTS idx_executions_session_id ON executions(session_id);
+
+		-- Seed the log from the legacy usage_stats table
+		INSERT INTO executions (command_id, full_command, directory, exit_code, success, executed_at)
+		SELECT us.command_id, c.full_command, c.directory, COALESCE(us.exit_code, 0),
+			COALESCE(us.success, 1), us.used_at
+		FROM usage_stats us
+		JOIN commands c ON c.id = us.command_id
+		WHERE NOT EXISTS (SELECT 1 FROM executions)
+		ORDER BY us.id;
+		`),
+	},
+	{
+		version:     3,
+		description: "unique command, keyword and flag rows",
+		up: execSQL(`
+		UPDATE commands SET directory = '' WHERE directory IS NULL;
+
+		-- Merge duplicate command rows into the oldest one
+		CREATE TEMP TABLE command_dupes AS
+		SELECT c.id AS old_id, k.keep_id
+		FROM commands c
+		JOIN (
+			SELECT MIN(id) AS keep_id, base, full_command, directory
+			FROM commands
+			GROUP BY base, full_command, directory
+		) k ON k.base = c.base AND k.full_command = c.full_command AND k.directory = c.directory
+		WHERE c.id <> k.keep_id;
+
+		UPDATE executions SET command_id = (SELECT keep_id FROM command_dupes WHERE old_id = command_id)
+		WHERE command_id IN (SELECT old_id FROM command_dupes);
+		UPDATE usage_stats SET command_id = (SELECT keep_id FROM command_dupes WHERE old_id = command_id)
+		WHERE command_id IN (SELECT old_id FROM command_dupes);
+		UPDATE keywords SET command_id = (SELECT keep_id FROM command_dupes WHERE old_id = command_id)
+		WHERE command_id IN (SELECT old_id FROM command_dupes);
+		UPDATE flags SET command_id = (SELECT keep_id FROM command_dupes WHERE old_id = command_id)
+		WHERE command_id IN (SELECT old_id FROM command_dupes);
+
+		UPDATE commands SET
+			frequency = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id),
+			last_used = COALESCE(
+				(SELECT MAX(executed_at) FROM executions WHERE command_id = commands.id),
+				last_used)
+		WHERE id IN (SELECT keep_id FROM command_dupes);
+
+		DELETE FROM commands WHERE id IN (SELECT old_id FROM command_dupes);
+		DROP TABLE command_dupes;
+
+		DELETE FROM keywords WHERE id NOT IN (SELECT MIN(id) FROM keywords GROUP BY command_id, keyword);
+		DELETE FROM flags WHERE id NOT IN (SELECT MIN(id) FROM flags GROUP BY command_id, flag);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_commands_identity ON commands(base, full_command, directory);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_keywords_command_keyword ON keywords(command_id, keyword);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_flags_command_flag ON flags(command_id, flag);
+		`),
+	},
+	{
+		version:     4,
+		description: "command segments of pipelines and lists",
+		up: execSQL(`
+		CREATE TABLE IF NOT EXISTS command_segments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			command_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			base TEXT NOT NULL,
+			subcommand TEXT,
+			full_segment TEXT NOT NULL,
+			op TEXT NOT NULL DEFAULT '',
+			UNIQUE (command_id, position),
+			FOREIGN KEY (command_id) REFERENCES commands(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_command_segments_base ON command_segments(base);
+
+		-- Existing commands were stored as a single segment
+		INSERT OR IGNORE INTO command_segments (command_id, position, base, subcommand, full_segment)
+		SELECT id, 0, base, subcommand, full_command FROM commands;
+		`),
+	},
+	{
+		version:     5,
+		description: "wrapper commands of segments",
+		up: execSQL(`
+		ALTER TABLE command_segments ADD COLUMN wrapper TEXT NOT NULL DEFAULT '';
+		`),
+	},
+	{
+		version:     6,
+		description: "frecency of commands",
+		up: execSQL(`
+		-- Filled in from the executions on the next open (see frecency.go)
+		ALTER TABLE commands ADD COLUMN frecency REAL;
+		CREATE INDEX IF NOT EXISTS idx_commands_frecency ON commands(frecency);
+
+		CREATE TABLE IF NOT EXISTS meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		`),
+	},
+	{
+		version:     7,
+		description: "indexes for ranking and search over the full history",
+		up: execSQL(`
+		-- Case-insensitive LIKE 'prefix%' can only use NOCASE indexes
+		CREATE INDEX IF NOT EXISTS idx_commands_full_command_nocase ON commands(full_command COLLATE NOCASE);
+		CREATE INDEX IF NOT EXISTS idx_commands_base_nocase ON commands(base COLLATE NOCASE);
+		CREATE INDEX IF NOT EXISTS idx_commands_last_used ON commands(last_used);
+		CREATE INDEX IF NOT EXISTS idx_commands_directory_frecency ON commands(directory, frecency);
+		DROP INDEX IF EXISTS idx_commands_directory;
+		`),
+	},
+	{
+		version:     8,
+		description: "transitions between consecutive commands of a session",
+		up: execSQL(`
+		CREATE TABLE IF NOT EXISTS transitions (
+			prev_command TEXT NOT NULL,
+			next_command TEXT NOT NULL,
+			directory TEXT NOT NULL DEFAULT '',
+			count INTEGER NOT NULL DEFAULT 0,
+			last_at DATETIME,
+			PRIMARY KEY (prev_command, next_command, directory)
+		);
+
+		-- Learn from the existing history: consecutive executions of a
+		-- session at most 30 minutes apart
+		INSERT INTO transitions (prev_command, next_command, directory, count, last_at)
+		SELECT prev_command, full_command, COALESCE(directory, ''), COUNT(*), MAX(executed_at)
+		FROM (
+			SELECT full_command, directory, executed_at,
+				LAG(full_command) OVER w AS prev_command,
+				LAG(executed_at) OVER w AS prev_at
+			FROM executions
+			WHERE session_id <> ''
+			WINDOW w AS (PARTITION BY session_id ORDER BY executed_at, id)
+		)
+		WHERE prev_command IS NOT NULL
+			AND julianday(executed_at) - julianday(prev_at) <= 30.0 / (24 * 60)
+		GROUP BY prev_command, full_command, COALESCE(directory, '');
+		`),
+	},
+	{
+		version:     9,
+		description: "git repository context of executions and commands",
+		up: execSQL(`
+		ALTER TABLE executions ADD COLUMN git_root TEXT NOT NULL DEFAULT '';
+		ALTER TABLE executions ADD COLUMN git_branch TEXT NOT NULL DEFAULT '';
+		ALTER TABLE executions ADD COLUMN git_remote TEXT NOT NULL DEFAULT '';
+
+		-- Project identity of a command's directory (see internal/project),
+		-- filled in for older history by 'kwik-cmd db reindex'
+		ALTER TABLE commands ADD COLUMN project TEXT NOT NULL DEFAULT '';
+		CREATE INDEX IF NOT EXISTS idx_commands_project_frecency ON commands(project, frecency);
+		`),
+	},
+	{
+		version:     10,
+		description: "project types of executions and commands",
+		up: execSQL(`
+		-- Comma separated, e.g. "go,make"
+		ALTER TABLE executions ADD COLUMN project_types TEXT NOT NULL DEFAULT '';
+
+		-- Project types of each command's directory as last seen
+		CREATE TABLE IF NOT EXISTS command_project_types (
+			command_id INTEGER NOT NULL,
+			project_type TEXT NOT NULL,
+			PRIMARY KEY (command_id, project_type),
+			FOREIGN KEY (command_id) REFERENCES commands(id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_command_project_types_type ON command_project_types(project_type);
+		`),
+	},
+	{
+		version:     11,
+		description: "success counts and failure frecency of commands",
+		up: execSQL(`
+		ALTER TABLE commands ADD COLUMN successes INTEGER NOT NULL DEFAULT 0;
+		UPDATE commands SET
+			successes = (SELECT COUNT(*) FROM executions WHERE command_id = commands.id AND success);
+
+		-- Filled in together with frecency on the next open (see frecency.go)
+		ALTER TABLE commands ADD COLUMN failure_frecency REAL;
+		DELETE FROM meta WHERE key = 'frecency_half_life_days';
+		`),
+	},
+	{
+		version:     12,
+		description: "covering index for fuzzy matching the most frecent commands",
+		up: execSQL(`
+		-- Fuzzy matching reads the text of the most frecent commands in
+		-- order; the texts in the index spare a table lookup for each
+		CREATE INDEX IF NOT EXISTS idx_commands_frecency_command ON commands(frecency, full_command);
+		DROP INDEX IF EXISTS idx_commands_frecency;
+		`),
+	},
+	{
+		version:     13,
+		description: "full-text index over commands",
+		up: execSQL(`
+		-- Arguments of every segment, filled in for older history by
+		-- 'kwik-cmd db reindex', and notes set with 'kwik-cmd note'
+		ALTER TABLE commands ADD COLUMN args TEXT NOT NULL DEFAULT '';
+		ALTER TABLE commands ADD COLUMN note TEXT NOT NULL DEFAULT '';
+
+		-- One row per command, rowid = commands.id (see fts.go)
+		CREATE VIRTUAL TABLE IF NOT EXISTS commands_fts USING fts5(
+			command, args, keywords, flags, directory, note
+		);
+		INSERT INTO commands_fts (rowid, command, args, keywords, flags, directory, note)
+		SELECT id, full_command, args,
+			COALESCE((SELECT group_concat(keyword, ' ') FROM keywords WHERE command_id = commands.id), ''),
+			COALESCE((SELECT group_concat(flag || ' ' || COALESCE(meaning, ''), ' ') FROM flags WHERE command_id = commands.id), ''),
+			COALESCE(directory, ''), note
+		FROM commands;
+
+		-- Keep the index in sync with the commands, their keywords and flags
+		CREATE TRIGGER IF NOT EXISTS commands_fts_insert AFTER INSERT ON commands BEGIN
+			INSERT INTO commands_fts (rowid, command, args, keywords, flags, directory, note)
+			VALUES (NEW.id, NEW.full_command, NEW.args, '', '', COALESCE(NEW.directory, ''), NEW.note);
+		END;
+		CREATE TRIGGER IF NOT EXISTS commands_fts_update AFTER UPDATE OF full_command, args, directory, note ON commands BEGIN
+			UPDATE commands_fts SET command = NEW.full_command, args = NEW.args,
+				directory = COALESCE(NEW.directory, ''), note = NEW.note
+			WHERE rowid = NEW.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS commands_fts_delete AFTER DELETE ON commands BEGIN
+			DELETE FROM commands_fts WHERE rowid = OLD.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS keywords_fts_insert AFTER INSERT ON keywords BEGIN
+			UPDATE commands_fts SET keywords =
+				(SELECT group_concat(keyword, ' ') FROM keywords WHERE command_id = NEW.command_id)
+			WHERE rowid = NEW.command_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS keywords_fts_delete AFTER DELETE ON keywords BEGIN
+			UPDATE commands_fts SET keywords =
+				COALESCE((SELECT group_concat(keyword, ' ') FROM keywords WHERE command_id = OLD.command_id), '')
+			WHERE rowid = OLD.command_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS flags_fts_insert AFTER INSERT ON flags BEGIN
+			UPDATE commands_fts SET flags =
+				(SELECT group_concat(flag || ' ' || COALESCE(meaning, ''), ' ') FROM flags WHERE command_id = NEW.command_id)
+			WHERE rowid = NEW.command_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS flags_fts_delete AFTER DELETE ON flags BEGIN
+			UPDATE commands_fts SET flags =
+				COALESCE((SELECT group_concat(flag || ' ' || COALESCE(meaning, ''), ' ') FROM flags WHERE command_id = OLD.command_id), '')
+			WHERE rowid = OLD.command_id;
+		END;
+		`),
+	},
+	{
+		version:     14,
+		description: "command templates",
+		up: execSQL(`
+		-- Commands and segments generalized over the arguments that vary
+		-- between runs, e.g. "kubectl logs {pod} -n {namespace}" (see
+		-- internal/parser/template.go). Older history is its own template
+		-- until 'kwik-cmd db reindex'.
+		ALTER TABLE commands ADD COLUMN template TEXT NOT NULL DEFAULT '';
+		UPDATE commands SET template = full_command;
+		CREATE INDEX IF NOT EXISTS idx_commands_template ON commands(template, last_used);
+
+		ALTER TABLE command_segments ADD COLUMN template TEXT NOT NULL DEFAULT '';
+		UPDATE command_segments SET template = full_segment;
+		`),
+	},
+	{
+		version:     15,
+		description: "argument values",
+		up: execSQL(`
+		-- The flag values and arguments of every segment by the slot they
+		-- fill, e.g. prod for -n of "kubectl get pods", completed by
+		-- 'kwik-cmd complete-arg'. Older history is filled in by
+		-- 'kwik-cmd db reindex'.
+		CREATE TABLE IF NOT EXISTS command_args (
+			command_id INTEGER NOT NULL,
+			base TEXT NOT NULL,
+			subcommand TEXT NOT NULL,
+			-- The flag the value was given with, '' for an argument
+			flag TEXT NOT NULL,
+			-- The index of an argument, 0 for a flag value
+			position INTEGER NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (command_id, base, subcommand, flag, position, value),
+			FOREIGN KEY (command_id) REFERENCES commands(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_command_args_slot ON command_args(base, flag, subcommand, position);
+		`),
+	},
+}
+
+// MigrationInfo describes a schema migration and whether it has been applied
+type MigrationInfo struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for a
+// database that has never been migrated
+func SchemaVersion() (int, error) {
+	if err := ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// LatestSchemaVersion returns the version the binary expects
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// MigrationStatus lists every known migration with its applied time
+func MigrationStatus() ([]MigrationInfo, error) {
+	if err := ensureVersionTable(); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]time.Time{}
+	rows, err := db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var infos []MigrationInfo
+	for _, m := range migrations {
+		info := MigrationInfo{Version: m.version, Description: m.description}
+		if t, ok := applied[m.version]; ok {
+			info.AppliedAt = &t
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Migrate applies pending migrations in order, backing up the database file
+// first. It returns the migrations that were applied.
+func Migrate() ([]MigrationInfo, error) {
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current == LatestSchemaVersion() {
+		return nil, nil
+	}
+
+	// Hooks start many processes at once; only one may migrate (and back up)
+	// at a time. The others wait and then find nothing left to do.
+	unlock, err := lockMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock database for migration: %w", err)
+	}
+	defer unlock()
+
+	current, err = SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if current > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than this kwik-cmd (%d); please upgrade",
+			current, LatestSchemaVersion())
+	}
+	if current == LatestSchemaVersion() {
+		return nil, nil
+	}
+
+	if err := useWAL(); err != nil {
+		return nil, err
+	}
+
+	if hasUserTables() {
+		backup, err := backupDatabase(current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up database: %w", err)
+		}
+		if backup != "" {
+			fmt.Fprintf(os.Stderr, "kwik-cmd: upgrading database schema v%d -> v%d (backup: %s)\n",
+				current, LatestSchemaVersion(), backup)
+		}
+	}
+
+	var applied []MigrationInfo
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(m); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		now := time.Now()
+		applied = append(applied, MigrationInfo{Version: m.version, Description: m.description, AppliedAt: &now})
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a migration and records it in a single transaction
+func applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Another process may have applied it since we read the version
+	var applied int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM schema_version WHERE version = ?", m.version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return tx.Commit()
+	}
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)
+	`, m.version, m.description, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockMigrations takes an exclusive lock on a file next to the database
+func lockMigrations() (func(), error) {
+	if dbPath == "" {
+		return func() {}, nil
+	}
+
+	f, err := os.OpenFile(dbPath+".migrate.lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// useWAL switches the database file to write-ahead logging, which lets
+// readers run alongside the hooks' writers. The mode is kept in the file, so
+// it is set once, under the migration lock: processes switching a new file
+// at the same time fail with "database is locked" without waiting.
+func useWAL() error {
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode=WAL").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to enable WAL: %w", err)
+	}
+	return nil
+}
+
+// ensureVersionTable creates the schema_version table of a new database.
+// It is created in an immediate transaction: an autocommit CREATE would
+// upgrade a read lock to a write lock, which fails at once instead of
+// waiting when another process starting on the same new file wrote first.
+func ensureVersionTable() error {
+	var exists int
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'schema_version'
+	`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasUserTables reports whether the database already holds kwik-cmd data,
+// i.e. whether a backup is worth taking
+func hasUserTables() bool {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'commands'
+	`).Scan(&count)
+	return err == nil && count > 0
+}
+
+// backupDatabase writes a consistent copy of the database next to it and
+// returns the backup path
+func backupDatabase(fromVersion int) (string, error) {
+	if dbPath == "" {
+		return "", nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s.bak", dbPath, fromVersion, time.Now().Format("20060102-150405"))
+	if _, err := db.Exec("VACUUM INTO ?", backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %d has version %d; versions must count up from 1", i, m.version)
+		}
+		if m.description == "" || m.up == nil {
+			t.Errorf("migration %d has no description or step", m.version)
+		}
+	}
+}
+
+func TestMigrateIdempotent(t *testing.T) {
+	openTestDB(t, 7)
+
+	for run := 0; run < 2; run++ {
+		applied, err := Migrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(applied) != 0 {
+			t.Errorf("migrating a current database applied %d migrations", len(applied))
+		}
+	}
+	// Re-applying a recorded migration is a no-op rather than an error
+	if err := applyMigration(migrations[0]); err != nil {
+		t.Errorf("re-applying migration 1: %v", err)
+	}
+
+	version, err := SchemaVersion()
+	if err != nil || version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion = %d, %v, want %d", version, err, LatestSchemaVersion())
+	}
+	status, err := MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range status {
+		if info.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not recorded as applied", info.Version, info.Description)
+		}
+	}
+	var rows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&rows); err != nil || rows != len(migrations) {
+		t.Errorf("schema_version holds %d rows, %v, want %d", rows, err, len(migrations))
+	}
+}
+
+// openVersion opens a new database migrated up to version
+func openVersion(t *testing.T, version int) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+	if err := ensureVersionTable(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:version] {
+		if err := applyMigration(m); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+	}
+}
+
+func TestMigrateInOrderWithBackup(t *testing.T) {
+	const from = 3
+	openVersion(t, from)
+	if _, err := db.Exec("INSERT INTO commands (base, full_command) VALUES ('make', 'make build')"); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != LatestSchemaVersion()-from {
+		t.Fatalf("applied %d migrations, want %d", len(applied), LatestSchemaVersion()-from)
+	}
+	for i, info := range applied {
+		if info.Version != from+1+i {
+			t.Errorf("applied migration %d in position %d", info.Version, i)
+		}
+	}
+
+	// The backup is a copy of the database as it was before migrating
+	backups, err := filepath.Glob(fmt.Sprintf("%s.v%d-*.bak", dbPath, from))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v, want one", backups, err)
+	}
+	backup, err := sql.Open("sqlite", backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	var version, commands int
+	if err := backup.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.QueryRow("SELECT COUNT(*) FROM commands").Scan(&commands); err != nil {
+		t.Fatal(err)
+	}
+	if version != from || commands != 1 {
+		t.Errorf("backup is at version %d with %d commands, want %d and 1", version, commands, from)
+	}
+}
+
+func TestMigrateNewDatabaseWithoutBackup(t *testing.T) {
+	openVersion(t, 0)
+	if _, err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := filepath.Glob(dbPath + ".*.bak"); len(backups) != 0 {
+		t.Errorf("a new database was backed up: %v", backups)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	openTestDB(t, 7)
+	if _, err := db.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, 'future', ?)",
+		LatestSchemaVersion()+1, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(); err == nil {
+		t.Error("migrating a database from a newer kwik-cmd succeeded")
+	}
+}
+
+// migrateEnv makes TestMigrateProcess open and migrate the database
+const migrateEnv = "KWIK_CMD_TEST_MIGRATE"
+
+// TestMigrateProcess is run by TestConcurrentMigrate in child processes; it
+// does nothing in a normal test run
+func TestMigrateProcess(t *testing.T) {
+	if os.Getenv(migrateEnv) == "" {
+		return
+	}
+	if _, err := config.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	Close()
+}
+
+// TestConcurrentMigrate starts processes that all migrate the same existing
+// database at once; the migration lock lets one migrate and back it up
+func TestConcurrentMigrate(t *testing.T) {
+	const processes = 8
+	const from = 5
+	openVersion(t, from)
+	if _, err := db.Exec("INSERT INTO commands (base, full_command) VALUES ('make', 'make build')"); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	env := append(os.Environ(), migrateEnv+"=1")
+	var wg sync.WaitGroup
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run := exec.Command(os.Args[0], "-test.run=^TestMigrateProcess$")
+			run.Env = env
+			if out, err := run.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("%v: %s", err, out)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := Open(); err != nil {
+		t.Fatal(err)
+	}
+	var rows, version int
+	if err := db.QueryRow("SELECT COUNT(*), MAX(version) FROM schema_version").Scan(&rows, &version); err != nil {
+		t.Fatal(err)
+	}
+	if rows != len(migrations) || version != LatestSchemaVersion() {
+		t.Errorf("schema_version holds %d rows up to %d, want %d up to %d", rows, version, len(migrations), LatestSchemaVersion())
+	}
+	if backups, _ := filepath.Glob(dbPath + ".*.bak"); len(backups) != 1 {
+		t.Errorf("backups = %v, want exactly one", backups)
+	}
+}
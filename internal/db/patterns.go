@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -16,17 +17,18 @@ type FailureStats struct {
 	LastFailure *time.Time
 }
 
-// GetFailureStats returns failure statistics for all commands
+// GetFailureStats returns failure statistics for all commands, derived from
+// the execution log
 func GetFailureStats() ([]FailureStats, error) {
 	rows, err := db.Query(`
 		SELECT 
 			c.id,
 			c.full_command,
-			COUNT(us.id) as total_runs,
-			SUM(CASE WHEN us.success = 0 THEN 1 ELSE 0 END) as failures,
-			MAX(CASE WHEN us.success = 0 THEN us.used_at END) as last_failure
+			COUNT(e.id) as total_runs,
+			SUM(CASE WHEN e.success = 0 THEN 1 ELSE 0 END) as failures,
+			MAX(CASE WHEN e.success = 0 THEN e.executed_at END) as last_failure
 		FROM commands c
-		LEFT JOIN usage_stats us ON c.id = us.command_id
+		LEFT JOIN executions e ON c.id = e.command_id
 		GROUP BY c.id
 		HAVING failures > 0
 		ORDER BY failures DESC
@@ -40,9 +42,13 @@ func GetFailureStats() ([]FailureStats, error) {
 	var stats []FailureStats
 	for rows.Next() {
 		var s FailureStats
-		if err := rows.Scan(&s.CommandID, &s.FullCommand, &s.TotalRuns, &s.Failures, &s.LastFailure); err != nil {
+		var lastFailure sql.NullString
+		if err := rows.Scan(&s.CommandID, &s.FullCommand, &s.TotalRuns, &s.Failures, &lastFailure); err != nil {
 			return nil, err
 		}
+		if t, ok := parseTimestamp(lastFailure.String); ok {
+			s.LastFailure = &t
+		}
 		if s.TotalRuns > 0 {
 			s.SuccessRate = float64(s.TotalRuns-s.Failures) / float64(s.TotalRuns) * 100
 		}
@@ -54,16 +60,22 @@ func GetFailureStats() ([]FailureStats, error) {
 // PatternGroup represents a group of related commands
 type PatternGroup struct {
 	BaseCommand string
-	Commands    []string
-	RunCount    int
+	// Commands are templates, so "kubectl logs {pod} -n {namespace}" stands
+	// for all the pods whose logs were read
+	Commands []string
+	RunCount int
 }
 
-// DetectPatterns detects command patterns (e.g., git subcommands, docker commands)
+// DetectPatterns detects command patterns (e.g., git subcommands, docker
+// commands). Commands are grouped by the programs they run, so
+// "make && ./bin/app | tee log" counts towards make, app and tee, and
+// near-duplicates are counted once per template.
 func DetectPatterns() ([]PatternGroup, error) {
 	rows, err := db.Query(`
-		SELECT base, COUNT(DISTINCT full_command) as cmd_count, SUM(frequency) as total_runs
-		FROM commands
-		GROUP BY base
+		SELECT s.base, COUNT(DISTINCT s.template) as cmd_count, SUM(c.frequency) as total_runs
+		FROM command_segments s
+		JOIN commands c ON c.id = s.command_id
+		GROUP BY s.base
 		HAVING cmd_count > 1
 		ORDER BY total_runs DESC
 		LIMIT 10
@@ -81,10 +93,13 @@ func DetectPatterns() ([]PatternGroup, error) {
 			return nil, err
 		}
 
-		// Get all commands for this base
+		// Get all command templates for this base
 		cmdRows, err := db.Query(`
-			SELECT full_command FROM commands 
-			WHERE base = ? ORDER BY frequency DESC LIMIT 10
+			SELECT s.template FROM command_segments s
+			JOIN commands c ON c.id = s.command_id
+			WHERE s.base = ?
+			GROUP BY s.template
+			ORDER BY SUM(c.frequency) DESC LIMIT 10
 		`, p.BaseCommand)
 		if err != nil {
 			continue
@@ -103,6 +118,75 @@ func DetectPatterns() ([]PatternGroup, error) {
 	return patterns, nil
 }
 
+// ProgramUsage is how often a program was run, whether on its own or as part
+// of a pipeline or list
+type ProgramUsage struct {
+	Base     string
+	RunCount int
+}
+
+// GetProgramUsage returns the most run programs across all command segments
+func GetProgramUsage(limit int) ([]ProgramUsage, error) {
+	rows, err := db.Query(`
+		SELECT s.base, SUM(c.frequency) as total_runs
+		FROM command_segments s
+		JOIN commands c ON c.id = s.command_id
+		GROUP BY s.base
+		ORDER BY total_runs DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []ProgramUsage
+	for rows.Next() {
+		var u ProgramUsage
+		if err := rows.Scan(&u.Base, &u.RunCount); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// SubcommandUsage is how often a tool was run with a given subcommand path,
+// e.g. kubectl "get pods"
+type SubcommandUsage struct {
+	Base       string
+	Subcommand string
+	RunCount   int
+}
+
+// GetSubcommandUsage returns the most run subcommand paths across all command
+// segments
+func GetSubcommandUsage(limit int) ([]SubcommandUsage, error) {
+	rows, err := db.Query(`
+		SELECT s.base, s.subcommand, SUM(c.frequency) as total_runs
+		FROM command_segments s
+		JOIN commands c ON c.id = s.command_id
+		WHERE s.subcommand <> ''
+		GROUP BY s.base, s.subcommand
+		ORDER BY total_runs DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []SubcommandUsage
+	for rows.Next() {
+		var u SubcommandUsage
+		if err := rows.Scan(&u.Base, &u.Subcommand, &u.RunCount); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
 // SuggestAlias suggests aliases based on command patterns
 func SuggestAliases() ([]string, error) {
 	patterns, err := DetectPatterns()
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Transitions form a first-order Markov model of the history: for every
+// pair of consecutive executions in a shell session the transitions table
+// counts how often next_command followed prev_command, per directory the
+// second command ran in. Commands are keyed by their text rather than by id
+// because a command has one id per directory.
+
+// transitionWindow is the longest gap between two executions of a session
+// for the second to count as following the first. Past it the user has
+// most likely moved on to something else.
+const transitionWindow = 30 * time.Minute
+
+// Prediction is a command that is likely to follow another one
+type Prediction struct {
+	FullCommand string
+	// Count is how often the command followed, in any directory
+	Count int
+	// Probability is the estimated chance that it comes next (0-1)
+	Probability float64
+}
+
+// recordTransition counts the execution e, stored with id, as following the
+// previous execution of its session
+func recordTransition(q execer, id int64, e Execution) error {
+	if e.SessionID == "" {
+		return nil
+	}
+
+	var prevCommand string
+	var prevAt time.Time
+	err := q.QueryRow(`
+		SELECT full_command, executed_at FROM executions
+		WHERE session_id = ? AND id < ?
+		ORDER BY id DESC LIMIT 1
+	`, e.SessionID, id).Scan(&prevCommand, &prevAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if gap := e.ExecutedAt.Sub(prevAt); gap < 0 || gap > transitionWindow {
+		return nil
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO transitions (prev_command, next_command, directory, count, last_at)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT (prev_command, next_command, directory) DO UPDATE SET
+			count = count + 1,
+			last_at = MAX(COALESCE(last_at, excluded.last_at), excluded.last_at)
+	`, prevCommand, e.FullCommand, e.Directory, e.ExecutedAt.UTC())
+	return err
+}
+
+// PredictNext returns the commands most likely to follow prevCommand, most
+// probable first. When the history has transitions in directory, the
+// probability is the average of the estimate for that directory and the
+// estimate over all directories, so local habits win without hiding the
+// global ones.
+func PredictNext(prevCommand, directory string, limit int) ([]Prediction, error) {
+	rows, err := db.Query(`
+		SELECT next_command,
+			SUM(count),
+			SUM(CASE WHEN directory = ? THEN count ELSE 0 END),
+			MAX(last_at)
+		FROM transitions
+		WHERE prev_command = ?
+		GROUP BY next_command
+	`, directory, prevCommand)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type counted struct {
+		Prediction
+		here   int
+		lastAt string
+	}
+	var candidates []counted
+	total, totalHere := 0, 0
+	for rows.Next() {
+		var c counted
+		var lastAt sql.NullString
+		if err := rows.Scan(&c.FullCommand, &c.Count, &c.here, &lastAt); err != nil {
+			return nil, err
+		}
+		c.lastAt = lastAt.String
+		total += c.Count
+		totalHere += c.here
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		c.Probability = float64(c.Count) / float64(total)
+		if directory != "" && totalHere > 0 {
+			c.Probability = (c.Probability + float64(c.here)/float64(totalHere)) / 2
+		}
+	}
+
+	// Most probable first; ties go to the transition seen last
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Probability != candidates[j].Probability {
+			return candidates[i].Probability > candidates[j].Probability
+		}
+		return lastTime(candidates[i].lastAt).After(lastTime(candidates[j].lastAt))
+	})
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	predictions := make([]Prediction, 0, len(candidates))
+	for _, c := range candidates {
+		predictions = append(predictions, c.Prediction)
+	}
+	return predictions, nil
+}
+
+// lastTime parses a MAX(last_at) aggregate, zero if it is missing
+func lastTime(value string) time.Time {
+	t, _ := parseTimestamp(value)
+	return t
+}
+
+// LastExecution returns the latest execution of a session, or of the whole
+// history for an empty session id. It returns nil if there is none.
+func LastExecution(sessionID string) (*Execution, error) {
+	executions, err := GetExecutions(ExecutionFilter{SessionID: sessionID, Limit: 1})
+	if err != nil || len(executions) == 0 {
+		return nil, err
+	}
+	return &executions[0], nil
+}
+
+// predictAfterSession predicts the commands following the last execution of
+// a session. Sessions that have not run anything get no predictions.
+func predictAfterSession(sessionID, directory string, limit int) ([]Prediction, error) {
+	last, err := LastExecution(sessionID)
+	if err != nil || last == nil {
+		return nil, err
+	}
+	return PredictNext(last.FullCommand, directory, limit)
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// recordSession stores commands run one minute apart in a session
+func recordSession(t *testing.T, session, directory string, start time.Time, commands ...string) {
+	t.Helper()
+	for i, c := range commands {
+		if _, err := RecordRun(Run{
+			Base:        c,
+			FullCommand: c,
+			Directory:   directory,
+			Execution: Execution{
+				Success:    true,
+				SessionID:  session,
+				ExecutedAt: start.Add(time.Duration(i) * time.Minute),
+			},
+		}); err != nil {
+			t.Fatalf("record %s: %v", c, err)
+		}
+	}
+}
+
+func TestPredictNext(t *testing.T) {
+	openTestDB(t, 7)
+	start := time.Now().Add(-2 * time.Hour)
+	recordSession(t, "a", "/repo", start, "git add -A", "git commit", "git push")
+	recordSession(t, "b", "/repo", start, "git add -A", "git commit", "git add -A", "git status")
+	// Past the transition window: not a sequence
+	recordSession(t, "c", "/repo", start.Add(-24*time.Hour), "git add -A")
+	recordSession(t, "c", "/repo", start, "make")
+
+	predictions, err := PredictNext("git add -A", "/repo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(predictions) != 2 || predictions[0].FullCommand != "git commit" || predictions[0].Count != 2 {
+		t.Fatalf("predictions = %+v, want git commit (2) first", predictions)
+	}
+	if p := predictions[0].Probability; p < 0.66 || p > 0.67 {
+		t.Errorf("probability = %.3f, want 2/3", p)
+	}
+}
+
+func TestRankPredictsNextCommand(t *testing.T) {
+	openTestDB(t, 7)
+	start := time.Now().Add(-time.Hour)
+	recordSession(t, "a", "/repo", start, "terraform plan", "terraform apply", "ls", "ls", "ls")
+	recordSession(t, "b", "/repo", start.Add(10*time.Minute), "terraform plan")
+
+	assertOrder(t, rankedOrder(t, "", "/repo")[:1], "ls")
+
+	ranked, err := GetRankedCommands(RankQuery{Directory: "/repo", SessionID: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranked[0].FullCommand != "terraform apply" {
+		t.Fatalf("top suggestion after terraform plan = %s, want terraform apply", ranked[0].FullCommand)
+	}
+}
@@ -1,86 +1,395 @@
 package db
 
 import (
+	"database/sql"
 	"math"
+	"sort"
+	"strings"
 	"time"
-)
 
-// Weighted ranking configuration
-const (
-	RecencyWeight     = 0.4
-	FrequencyWeight   = 0.4
-	DirectoryWeight  = 0.2
-	DaysSinceMaxScore = 30.0 // Commands older than 30 days get 0 recency score
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/fuzzy"
+	"github.com/samber/lo"
 )
 
 // RankedCommand includes ranking score
 type RankedCommand struct {
 	Command
-	Score float64
+	// Frecency is the decayed execution count (see frecency.go)
+	Frecency float64
+	// ProjectTypes are the kinds of project the command's directory is
+	// (go, node, ...), loaded when ranking for a typed project
+	ProjectTypes []string
+	// Successes is the number of runs that exited with status 0
+	Successes int
+	// FailureRate is the share of recent runs that failed, weighted like
+	// frecency
+	FailureRate float64
+	// Positions are the indexes of the runes of FullCommand matched by what
+	// was typed or searched for, for highlighting
+	Positions []int
+	Score     float64
+	// Explanation breaks Score down, when asked for with RankQuery.Explain
+	Explanation *Explanation
+	// Variants is the number of commands sharing the template, when they
+	// are collapsed with RankQuery.Templates
+	Variants int
+
+	// matchQuality is how well the partial fuzzy matches, 0-1
+	matchQuality float64
+}
+
+// NeverSucceeded reports whether every recorded run of the command failed
+func (c RankedCommand) NeverSucceeded() bool {
+	return c.Frequency > 0 && c.Successes == 0
+}
+
+// RankQuery describes what to rank commands for
+type RankQuery struct {
+	// Partial is what has been typed so far
+	Partial string
+	// Directory is the current working directory
+	Directory string
+	// Project is the identity of the project Directory belongs to (see
+	// internal/project). Commands from anywhere in the same project count
+	// almost as much as commands from Directory itself.
+	Project string
+	// ProjectTypes are the kinds of project Directory is (go, node, ...).
+	// Commands run in other projects of the same kinds are boosted.
+	ProjectTypes []string
+	// SessionID is the shell session asking. With nothing typed yet, the
+	// commands that usually follow the session's last command are boosted.
+	SessionID string
+	// Limit caps the number of results (0 for no limit)
+	Limit int
+	// IncludeFailed keeps commands that have never succeeded, which are
+	// left out by default
+	IncludeFailed bool
+	// Explain fills in the Explanation of every result
+	Explain bool
+	// Templates collapses commands sharing a template into one suggestion
+	// filled with the values of the most recently used of them
+	Templates bool
+}
+
+// ranker scores commands. The signal weights come from config
+// (recency_weight, frequency_weight, directory_weight, project_type_weight,
+// prediction_weight, failure_weight).
+type ranker struct {
+	now          time.Time
+	halfLifeDays float64
+	// maxFrecency is the highest frecency in the whole history, so the
+	// frequency signal is relative to how much the user really runs things
+	maxFrecency       float64
+	recencyWeight     float64
+	frequencyWeight   float64
+	directoryWeight   float64
+	projectTypeWeight float64
+	predictionWeight  float64
+	failureWeight     float64
+	// predicted holds the probability of each command following the
+	// session's last command
+	predicted map[string]float64
+}
+
+// recencyScore is 1 for a command run now, halving every half-life
+func (r *ranker) recencyScore(c RankedCommand) float64 {
+	if c.LastUsed.IsZero() {
+		return 0
+	}
+	return math.Min(1, decay(c.LastUsed, r.now, r.halfLifeDays))
+}
+
+// frequencyScore maps frecency onto 0-1 against the busiest command. The
+// log scale keeps a command run 10,000 times ahead of one run 10 times
+// without flattening everything else to 0.
+func (r *ranker) frequencyScore(c RankedCommand) float64 {
+	if r.maxFrecency <= 0 {
+		return 0
+	}
+	return math.Min(1, math.Log1p(c.Frecency)/math.Log1p(r.maxFrecency))
 }
 
-// GetRankedCommands returns commands sorted by weighted ranking score
-func GetRankedCommands(partial, currentDir string, limit int) ([]RankedCommand, error) {
-	commands, err := GetRecentCommands(100)
+// Context scores of a command for where it was run relative to the current
+// directory
+const (
+	sameDirectoryScore = 1.0
+	// sameProjectScore covers other directories of the project and other
+	// clones of it
+	sameProjectScore = 0.8
+	// relatedDirectoryScore covers parents and children of the current
+	// directory outside a project
+	relatedDirectoryScore = 0.5
+)
+
+// directoryScore scores where a command was run: in the current directory,
+// in the same project, or in a parent or child of the current directory
+func directoryScore(c RankedCommand, rq RankQuery) float64 {
+	switch {
+	case rq.Directory != "" && c.Directory == rq.Directory:
+		return sameDirectoryScore
+	case rq.Project != "" && c.Project == rq.Project:
+		return sameProjectScore
+	case rq.Directory != "" && c.Directory != "" &&
+		(containsParent(rq.Directory, c.Directory) || containsParent(c.Directory, rq.Directory)):
+		return relatedDirectoryScore
+	}
+	return 0
+}
+
+// projectTypeScore is the overlap (Jaccard index) of the kinds of project
+// the command was run in and the kinds of the current directory, so "go test
+// ./..." from any Go project scores 1 in another Go project
+func projectTypeScore(c RankedCommand, rq RankQuery) float64 {
+	if len(c.ProjectTypes) == 0 || len(rq.ProjectTypes) == 0 {
+		return 0
+	}
+	shared := len(lo.Intersect(c.ProjectTypes, rq.ProjectTypes))
+	return float64(shared) / float64(len(lo.Union(c.ProjectTypes, rq.ProjectTypes)))
+}
+
+// Bonuses for matching what has been typed
+const (
+	prefixMatchScore = 0.3
+	// fuzzyMatchScore is for a perfect fuzzy match that is not a prefix,
+	// scaled down with the quality of the match
+	fuzzyMatchScore = 0.2
+)
+
+// matchPartial fuzzy matches the partial against the commands, recording
+// the matched positions and the quality of the match
+func matchPartial(ranked []RankedCommand, partial string) {
+	if partial == "" {
+		return
+	}
+	perfect := float64(fuzzy.PerfectScore(partial))
+	for i := range ranked {
+		c := &ranked[i]
+		if m, ok := fuzzy.Find(partial, c.FullCommand); ok && perfect > 0 {
+			c.Positions = m.Positions
+			c.matchQuality = math.Max(0, math.Min(1, float64(m.Score)/perfect))
+		}
+	}
+}
+
+// isPrefixMatch reports whether the command starts with the partial, which
+// is rewarded most; otherwise the partial may still fuzzy match ("gcm" for
+// "git commit -m")
+func isPrefixMatch(c RankedCommand, partial string) bool {
+	return strings.HasPrefix(c.Base, partial) || strings.HasPrefix(c.FullCommand, partial)
+}
+
+// score is the sum of the command's signals (see explain.go)
+func (r *ranker) score(c RankedCommand, rq RankQuery) float64 {
+	var total float64
+	for _, s := range r.signals(c, rq) {
+		total += s.Score
+	}
+	return total
+}
+
+// newRanker prepares scoring against the current state of the history
+func newRanker(now time.Time) (*ranker, error) {
+	halfLife, err := storedHalfLife(db)
 	if err != nil {
 		return nil, err
 	}
 
+	var top sql.NullFloat64
+	if err := db.QueryRow("SELECT MAX(frecency) FROM commands").Scan(&top); err != nil {
+		return nil, err
+	}
+
+	cfg := config.Get()
+	return &ranker{
+		now:               now,
+		halfLifeDays:      halfLife,
+		maxFrecency:       frecencyAt(top, now, halfLife),
+		recencyWeight:     cfg.RecencyWeight,
+		frequencyWeight:   cfg.FrequencyWeight,
+		directoryWeight:   cfg.DirectoryWeight,
+		projectTypeWeight: cfg.ProjectTypeWeight,
+		predictionWeight:  cfg.PredictionWeight,
+		failureWeight:     cfg.FailureWeight,
+	}, nil
+}
+
+// rankCandidates is how many commands each signal contributes to the set
+// that is scored. Every candidate query is served by an index, so ranking
+// cost does not grow with the size of the history.
+const rankCandidates = 50
+
+// selectiveMatches is the number of matches up to which a filtered candidate
+// query sorts all its matches. Beyond it, walking the index of the ordering
+// and filtering finds the first rows sooner.
+const selectiveMatches = 2000
+
+// ordering is a sort order of candidate queries and the index serving it
+type ordering struct {
+	orderBy string
+	index   string
+}
+
+var (
+	byFrecency = ordering{"frecency DESC", "idx_commands_frecency_command"}
+	byRecency  = ordering{"last_used DESC", "idx_commands_last_used"}
+)
+
+// prefixMatch selects commands whose text or base starts with a likePrefix
+// pattern, given twice
+const prefixMatch = "(full_command LIKE ? ESCAPE '\\' OR base LIKE ? ESCAPE '\\')"
+
+// succeededMatch selects commands that have succeeded at least once, or
+// have no runs yet; the others are what RankedCommand.NeverSucceeded reports
+const succeededMatch = "(successes > 0 OR frequency = 0)"
+
+// subsequenceMatch selects commands whose text contains the characters of a
+// likeSubsequence pattern in order, the candidates for a fuzzy match
+const subsequenceMatch = "full_command LIKE ? ESCAPE '\\'"
+
+// fuzzyWindow is the number of most frecent commands searched for fuzzy
+// matches of the partial. Subsequence patterns cannot use an index, so
+// looking only at these keeps fuzzy ranking cheap on a huge history;
+// prefix matches are still found anywhere.
+const fuzzyWindow = 5000
+
+// rankColumns are the columns scanned by scanRanked
+const rankColumns = "id, base, subcommand, full_command, frequency, last_used, directory, project, template, successes, frecency, failure_frecency"
+
+// GetRankedCommands returns commands sorted by weighted ranking score. The
+// whole history is considered: the best commands by frecency, by recency, in
+// and around the current directory, in the current project, by prefix and,
+// before anything is typed, those predicted to follow the session's last
+// command are fetched from indexes and scored together. The partial is
+// matched fuzzily, so "gcm" finds "git commit -m".
+func GetRankedCommands(rq RankQuery) ([]RankedCommand, error) {
 	now := time.Now()
-	var ranked []RankedCommand
+	r, err := newRanker(now)
+	if err != nil {
+		return nil, err
+	}
+	partial, currentDir, limit := rq.Partial, rq.Directory, rq.Limit
+
+	n := rankCandidates
+	if limit > n {
+		n = limit
+	}
 
-	for _, c := range commands {
-		// Calculate recency score (0-1, newer = higher)
-		daysSince := now.Sub(c.LastUsed).Hours() / 24
-		recencyScore := math.Max(0, 1-(daysSince/DaysSinceMaxScore))
-
-		// Frequency score (0-1, normalize based on max frequency)
-		maxFreq := 10 // Assume max frequency of 10 for normalization
-		frequencyScore := math.Min(1, float64(c.Frequency)/float64(maxFreq))
-
-		// Directory match score
-		directoryScore := 0.0
-		if currentDir != "" && c.Directory != "" {
-			if c.Directory == currentDir {
-				directoryScore = 1.0
-			} else if containsParent(currentDir, c.Directory) || containsParent(c.Directory, currentDir) {
-				directoryScore = 0.5
+	type candidateQuery struct {
+		where string
+		args  []interface{}
+		by    ordering
+	}
+	queries := []candidateQuery{
+		{"", nil, byFrecency},
+		{"", nil, byRecency},
+	}
+	if currentDir != "" {
+		queries = append(queries,
+			candidateQuery{"directory = ?", []interface{}{currentDir}, byFrecency},
+			// Subdirectories sort between "dir/" and "dir0" ('0' follows '/')
+			candidateQuery{"directory > ? AND directory < ?",
+				[]interface{}{currentDir + "/", currentDir + "0"}, byFrecency},
+		)
+		if rq.Project != "" {
+			queries = append(queries, candidateQuery{"project = ?", []interface{}{rq.Project}, byFrecency})
+		}
+		if parents := parentDirs(currentDir); len(parents) > 0 {
+			queries = append(queries, candidateQuery{
+				"directory IN " + placeholders(len(parents)), parents, byFrecency})
+		}
+	}
+	if len(rq.ProjectTypes) > 0 && r.projectTypeWeight > 0 {
+		types := make([]interface{}, len(rq.ProjectTypes))
+		for i, t := range rq.ProjectTypes {
+			types[i] = t
+		}
+		queries = append(queries, candidateQuery{
+			"id IN (SELECT command_id FROM command_project_types WHERE project_type IN " +
+				placeholders(len(types)) + ")", types, byFrecency})
+	}
+	if partial == "" && rq.SessionID != "" && r.predictionWeight > 0 {
+		predicted, err := predictAfterSession(rq.SessionID, currentDir, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(predicted) > 0 {
+			r.predicted = map[string]float64{}
+			var commands []interface{}
+			for _, p := range predicted {
+				r.predicted[p.FullCommand] = p.Probability
+				commands = append(commands, p.FullCommand)
 			}
+			queries = append(queries, candidateQuery{
+				"full_command IN " + placeholders(len(commands)), commands, byFrecency})
 		}
+	}
+	if partial != "" {
+		pattern := likePrefix(partial)
+		queries = append(queries,
+			candidateQuery{prefixMatch, []interface{}{pattern, pattern}, byFrecency},
+			candidateQuery{prefixMatch, []interface{}{pattern, pattern}, byRecency},
+		)
+	}
 
-		// Partial match bonus
-		partialScore := 0.0
-		if partial != "" {
-			lowerPartial := partial
-			lowerBase := c.Base
-			lowerFull := c.FullCommand
-			if len(lowerPartial) > 0 && (len(lowerBase) >= len(lowerPartial) && lowerBase[:len(lowerPartial)] == lowerPartial ||
-				len(lowerFull) >= len(lowerPartial) && lowerFull[:len(lowerPartial)] == lowerPartial) {
-				partialScore = 0.3 // Boost exact prefix matches
+	seen := map[int64]bool{}
+	var ranked []RankedCommand
+	add := func(candidates []scannedCommand) {
+		for _, c := range candidates {
+			if seen[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			if !rq.IncludeFailed && c.NeverSucceeded() {
+				continue
 			}
+			c.Frecency = frecencyAt(c.stored, now, r.halfLifeDays)
+			ranked = append(ranked, c.RankedCommand)
 		}
-
-		// Calculate total score
-		score := (RecencyWeight*recencyScore +
-			FrequencyWeight*frequencyScore +
-			DirectoryWeight*directoryScore +
-			partialScore)
-
-		ranked = append(ranked, RankedCommand{
-			Command: c,
-			Score:   score,
-		})
+	}
+	for _, q := range queries {
+		candidates, err := topCommands(q.where, q.args, q.by, n)
+		if err != nil {
+			return nil, err
+		}
+		add(candidates)
+	}
+	if partial != "" {
+		candidates, err := fuzzyCommands(partial, n)
+		if err != nil {
+			return nil, err
+		}
+		add(candidates)
 	}
 
-	// Sort by score descending
-	for i := 0; i < len(ranked)-1; i++ {
-		for j := i + 1; j < len(ranked); j++ {
-			if ranked[j].Score > ranked[i].Score {
-				ranked[i], ranked[j] = ranked[j], ranked[i]
-			}
+	if len(rq.ProjectTypes) > 0 {
+		if err := loadProjectTypes(ranked); err != nil {
+			return nil, err
 		}
 	}
+	matchPartial(ranked, partial)
+	for i := range ranked {
+		if rq.Explain {
+			ranked[i].Explanation = r.explain(ranked[i], rq)
+			ranked[i].Score = ranked[i].Explanation.Score
+		} else {
+			ranked[i].Score = r.score(ranked[i], rq)
+		}
+	}
+
+	// Sort by score descending; ties go to the more recent command
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].LastUsed.After(ranked[j].LastUsed)
+	})
 
+	if rq.Templates {
+		if ranked, err = collapseTemplates(ranked, partial); err != nil {
+			return nil, err
+		}
+	}
 	if limit > 0 && len(ranked) > limit {
 		ranked = ranked[:limit]
 	}
@@ -88,6 +397,228 @@ func GetRankedCommands(partial, currentDir string, limit int) ([]RankedCommand,
 	return ranked, nil
 }
 
+// topCommands returns the first n commands matching where in the given order
+func topCommands(where string, args []interface{}, by ordering, n int) ([]scannedCommand, error) {
+	from := "commands"
+	if where != "" {
+		// A broad filter ("g", a parent directory) matches much of the history;
+		// sorting all of it is slower than walking the ordering's index
+		var matches int
+		count := "SELECT COUNT(*) FROM (SELECT 1 FROM commands WHERE " + where + " LIMIT ?)"
+		if err := db.QueryRow(count, append(args[:len(args):len(args)], selectiveMatches+1)...).Scan(&matches); err != nil {
+			return nil, err
+		}
+		if matches > selectiveMatches {
+			from += " INDEXED BY " + by.index
+		}
+		where = " WHERE " + where
+	}
+
+	query := "SELECT " + rankColumns + " FROM " + from + where + " ORDER BY " + by.orderBy + " LIMIT ?"
+	return scanRanked(db.Query(query, append(args[:len(args):len(args)], n)...))
+}
+
+// fuzzyCommands returns the first n commands by frecency among the
+// fuzzyWindow most frecent that contain the characters of partial in order
+func fuzzyCommands(partial string, n int) ([]scannedCommand, error) {
+	// The window is read from the covering index in frecency order, so the
+	// first n matches are the most frecent without sorting the window
+	query := "SELECT " + rankColumns + " FROM commands WHERE id IN (" +
+		"SELECT id FROM (SELECT id, full_command FROM commands INDEXED BY " + byFrecency.index +
+		" ORDER BY frecency DESC LIMIT ?) WHERE " + subsequenceMatch + " LIMIT ?)" +
+		" ORDER BY frecency DESC"
+	return scanRanked(db.Query(query, fuzzyWindow, likeSubsequence(partial), n))
+}
+
+// scannedCommand is a ranked command together with its stored frecency
+type scannedCommand struct {
+	RankedCommand
+	stored sql.NullFloat64
+}
+
+// scanRanked reads rows selected with rankColumns
+func scanRanked(rows *sql.Rows, err error) ([]scannedCommand, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []scannedCommand
+	for rows.Next() {
+		var c scannedCommand
+		var failures sql.NullFloat64
+		if err := rows.Scan(&c.ID, &c.Base, &c.Subcommand, &c.FullCommand, &c.Frequency,
+			&c.LastUsed, &c.Directory, &c.Project, &c.Template, &c.Successes, &c.stored, &failures); err != nil {
+			return nil, err
+		}
+		c.FailureRate = failureRate(c.stored, failures)
+		commands = append(commands, c)
+	}
+	return commands, rows.Err()
+}
+
+// GetRecentByPrefix returns the most recently used commands starting with
+// prefix (case insensitive), or all commands for an empty prefix. Commands
+// that have never succeeded are left out unless includeFailed is set.
+func GetRecentByPrefix(prefix string, limit int, includeFailed bool) ([]Command, error) {
+	return commandsByPrefix(prefix, byRecency, limit, includeFailed)
+}
+
+// GetFrecentByPrefix returns the most frecent commands starting with prefix
+// (case insensitive), or all commands for an empty prefix. Commands that
+// have never succeeded are left out unless includeFailed is set.
+func GetFrecentByPrefix(prefix string, limit int, includeFailed bool) ([]Command, error) {
+	return commandsByPrefix(prefix, byFrecency, limit, includeFailed)
+}
+
+func commandsByPrefix(prefix string, by ordering, limit int, includeFailed bool) ([]Command, error) {
+	var conditions []string
+	var args []interface{}
+	if prefix != "" {
+		pattern := likePrefix(prefix)
+		conditions = append(conditions, prefixMatch)
+		args = append(args, pattern, pattern)
+	}
+	// Filtered in SQL rather than after the limit, so the list stays full
+	if !includeFailed {
+		conditions = append(conditions, succeededMatch)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	scanned, err := topCommands(where, args, by, limit)
+	if err != nil {
+		return nil, err
+	}
+	commands := make([]Command, 0, len(scanned))
+	for _, c := range scanned {
+		commands = append(commands, c.Command)
+	}
+	return commands, nil
+}
+
+// searchCandidates is how many of the most frecent commands containing the
+// search words in order are fuzzy scored by SearchCommands
+const searchCandidates = 500
+
+// SearchCommands returns commands fuzzy matching every word (case
+// insensitive, see internal/fuzzy), best match first and the more frecent
+// of equal matches first. Score is the fuzzy score.
+func SearchCommands(words []string, limit int) ([]RankedCommand, error) {
+	now := time.Now()
+	halfLife, err := storedHalfLife(db)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + rankColumns + " FROM commands WHERE 1 = 1"
+	var args []interface{}
+	for _, w := range words {
+		query += " AND " + subsequenceMatch
+		args = append(args, likeSubsequence(w))
+	}
+	query += " ORDER BY frecency DESC, last_used DESC LIMIT ?"
+	args = append(args, max(limit, searchCandidates))
+
+	scanned, err := scanRanked(db.Query(query, args...))
+	if err != nil {
+		return nil, err
+	}
+	terms := strings.Join(words, " ")
+	commands := make([]RankedCommand, 0, len(scanned))
+	for _, c := range scanned {
+		m, ok := fuzzy.FindTerms(terms, c.FullCommand)
+		if !ok {
+			continue
+		}
+		c.Frecency = frecencyAt(c.stored, now, halfLife)
+		c.Positions = m.Positions
+		c.Score = float64(m.Score)
+		commands = append(commands, c.RankedCommand)
+	}
+
+	// The candidates are in frecency order already
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].Score > commands[j].Score
+	})
+	if limit > 0 && len(commands) > limit {
+		commands = commands[:limit]
+	}
+	return commands, nil
+}
+
+// loadProjectTypes fills in the project types of ranked commands
+func loadProjectTypes(ranked []RankedCommand) error {
+	if len(ranked) == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(ranked))
+	ids := make([]interface{}, len(ranked))
+	for i, c := range ranked {
+		index[c.ID] = i
+		ids[i] = c.ID
+	}
+
+	rows, err := db.Query("SELECT command_id, project_type FROM command_project_types WHERE command_id IN "+
+		placeholders(len(ids)), ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var t string
+		if err := rows.Scan(&id, &t); err != nil {
+			return err
+		}
+		c := &ranked[index[id]]
+		c.ProjectTypes = append(c.ProjectTypes, t)
+	}
+	return rows.Err()
+}
+
+// placeholders returns a parenthesized list of n SQL parameters, "(?, ?)"
+func placeholders(n int) string {
+	return "(?" + strings.Repeat(", ?", n-1) + ")"
+}
+
+// parentDirs returns every ancestor of dir, nearest first
+func parentDirs(dir string) []interface{} {
+	var parents []interface{}
+	for {
+		idx := strings.LastIndex(dir, "/")
+		if idx <= 0 {
+			if idx == 0 && dir != "/" {
+				parents = append(parents, "/")
+			}
+			return parents
+		}
+		dir = dir[:idx]
+		parents = append(parents, dir)
+	}
+}
+
+// likePrefix returns a LIKE pattern matching strings that start with prefix
+func likePrefix(prefix string) string {
+	return escapeLike(prefix) + "%"
+}
+
+// likeSubsequence returns a LIKE pattern matching strings that contain the
+// characters of s in order, with anything between them
+func likeSubsequence(s string) string {
+	var b strings.Builder
+	b.WriteString("%")
+	for _, r := range s {
+		b.WriteString(escapeLike(string(r)))
+		b.WriteString("%")
+	}
+	return b.String()
+}
+
+// escapeLike escapes LIKE wildcards so s matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // containsParent checks if path1 is a parent of path2 or vice versa
 func containsParent(path1, path2 string) bool {
 	if len(path1) > len(path2) {
@@ -101,7 +632,11 @@ func containsParent(path1, path2 string) bool {
 
 // AddKeyword adds a keyword to a command
 func AddKeyword(commandID int64, keyword string) error {
-	_, err := db.Exec(`
+	return addKeyword(db, commandID, keyword)
+}
+
+func addKeyword(q execer, commandID int64, keyword string) error {
+	_, err := q.Exec(`
 		INSERT OR IGNORE INTO keywords (command_id, keyword) VALUES (?, ?)
 	`, commandID, keyword)
 	return err
@@ -109,7 +644,11 @@ func AddKeyword(commandID int64, keyword string) error {
 
 // AddFlag adds a flag to a command
 func AddFlag(commandID int64, flag, meaning string) error {
-	_, err := db.Exec(`
+	return addFlag(db, commandID, flag, meaning)
+}
+
+func addFlag(q execer, commandID int64, flag, meaning string) error {
+	_, err := q.Exec(`
 		INSERT OR IGNORE INTO flags (command_id, flag, meaning) VALUES (?, ?, ?)
 	`, commandID, flag, meaning)
 	return err
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// benchHistorySize is the number of distinct commands in the benchmark
+// history. Ghost text calls GetRankedCommands on every keystroke, so it
+// should stay well under 10ms per call at this size.
+const benchHistorySize = 100000
+
+// benchTemplate is a seeded database copied into each benchmark, so the
+// history is only generated once per run
+var benchTemplate string
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchTemplate != "" {
+		os.RemoveAll(filepath.Dir(benchTemplate))
+	}
+	os.Exit(code)
+}
+
+// openBenchDB opens a database holding the benchmark history
+func openBenchDB(b *testing.B) {
+	b.Helper()
+	openTestDB(b, 7)
+
+	if benchTemplate == "" {
+		seedHistory(b, benchHistorySize)
+		dir, err := os.MkdirTemp("", "kwik-cmd-bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchTemplate = filepath.Join(dir, "history.db")
+		if _, err := db.Exec("VACUUM INTO ?", benchTemplate); err != nil {
+			b.Fatal(err)
+		}
+		return
+	}
+
+	Close()
+	path := Path()
+	data, err := os.ReadFile(benchTemplate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	if err := Init(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// seedHistory fills the database with n distinct commands spread over a year
+// and a few hundred directories, with a long-tailed number of runs each
+func seedHistory(b *testing.B, n int) {
+	b.Helper()
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	tools := []string{"git", "docker", "kubectl", "go", "npm", "make", "ls", "cd", "vim", "grep", "ssh", "curl"}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	insertCommand, err := tx.Prepare(`
+		INSERT INTO commands (base, subcommand, full_command, directory, frequency)
+		VALUES (?, ?, ?, ?, 0)
+	`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	insertExecution, err := tx.Prepare(`
+		INSERT INTO executions (command_id, full_command, directory, exit_code, success, executed_at)
+		VALUES (?, ?, ?, 0, 1, ?)
+	`)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		base := tools[rng.Intn(len(tools))]
+		full := fmt.Sprintf("%s run-%d --flag %d", base, i, rng.Intn(1000))
+		dir := fmt.Sprintf("/home/user/src/project-%d", rng.Intn(300))
+
+		result, err := insertCommand.Exec(base, "run", full, dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		id, _ := result.LastInsertId()
+
+		runs := 1 + int(rng.ExpFloat64()*2)
+		if rng.Intn(1000) == 0 {
+			runs += 500
+		}
+		for r := 0; r < runs; r++ {
+			at := now.Add(-time.Duration(rng.Int63n(int64(365 * 24 * time.Hour))))
+			if _, err := insertExecution.Exec(id, full, dir, at.UTC()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	if err := RebuildAggregates(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func benchmarkRank(b *testing.B, partial, dir string) {
+	openBenchDB(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := GetRankedCommands(RankQuery{Partial: partial, Directory: dir, Limit: 1}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRankNoPrefix(b *testing.B) {
+	benchmarkRank(b, "", "/home/user/src/project-42")
+}
+
+func BenchmarkRankCommonPrefix(b *testing.B) {
+	benchmarkRank(b, "g", "/home/user/src/project-42")
+}
+
+func BenchmarkRankRarePrefix(b *testing.B) {
+	benchmarkRank(b, "kubectl run-4242", "/home/user/src/project-42")
+}
+
+func BenchmarkRankParentDirectory(b *testing.B) {
+	benchmarkRank(b, "git", "/home/user")
+}
+
+func BenchmarkSearch(b *testing.B) {
+	openBenchDB(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := SearchCommands([]string{"docker", "flag 7"}, 20); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
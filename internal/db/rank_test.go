@@ -0,0 +1,415 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+// openTestDB opens a fresh database in a temporary home directory with the
+// given frecency half-life
+func openTestDB(t testing.TB, halfLifeDays float64) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.FrecencyHalfLifeDays = halfLifeDays
+
+	if err := Init(); err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() { Close() })
+}
+
+// record stores runs of a command, one per timestamp
+func record(t testing.TB, fullCommand, directory string, times ...time.Time) {
+	t.Helper()
+	for _, at := range times {
+		if _, err := RecordRun(Run{
+			Base:        fullCommand,
+			FullCommand: fullCommand,
+			Directory:   directory,
+			Execution:   Execution{Success: true, ExecutedAt: at},
+		}); err != nil {
+			t.Fatalf("record %s: %v", fullCommand, err)
+		}
+	}
+}
+
+// repeat returns n copies of t
+func repeat(t time.Time, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = t
+	}
+	return times
+}
+
+func rankedOrder(t *testing.T, partial, dir string) []string {
+	t.Helper()
+	ranked, err := GetRankedCommands(RankQuery{Partial: partial, Directory: dir})
+	if err != nil {
+		t.Fatalf("rank: %v", err)
+	}
+	order := make([]string, len(ranked))
+	for i, rc := range ranked {
+		order[i] = rc.FullCommand
+	}
+	return order
+}
+
+func assertOrder(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRankFrequencyIsNotCapped(t *testing.T) {
+	openTestDB(t, 7)
+	at := time.Now().Add(-time.Hour)
+	record(t, "git log", "/src", repeat(at, 10)...)
+	record(t, "git status", "/src", repeat(at, 200)...)
+
+	ranked, err := GetRankedCommands(RankQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrder(t, []string{ranked[0].FullCommand, ranked[1].FullCommand}, "git status", "git log")
+	if ranked[0].Score-ranked[1].Score < 0.1 {
+		t.Errorf("200 runs should clearly beat 10 runs, got scores %.3f and %.3f", ranked[0].Score, ranked[1].Score)
+	}
+}
+
+func TestRankDecaysOldHistory(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-90 * 24 * time.Hour)
+
+	t.Run("short half-life favours recent runs", func(t *testing.T) {
+		openTestDB(t, 7)
+		record(t, "make old", "", repeat(old, 30)...)
+		record(t, "make new", "", repeat(now, 3)...)
+		assertOrder(t, rankedOrder(t, "", ""), "make new", "make old")
+	})
+
+	t.Run("long half-life favours frequent runs", func(t *testing.T) {
+		openTestDB(t, 365)
+		record(t, "make old", "", repeat(old, 30)...)
+		record(t, "make new", "", repeat(now, 3)...)
+		assertOrder(t, rankedOrder(t, "", ""), "make old", "make new")
+	})
+}
+
+func TestRankDirectoryAndPrefix(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "npm test", "/web", repeat(now.Add(-1*time.Hour), 5)...)
+	record(t, "go test ./...", "/api", repeat(now.Add(-2*time.Hour), 5)...)
+	record(t, "ls", "/home", repeat(now.Add(-3*time.Hour), 5)...)
+
+	assertOrder(t, rankedOrder(t, "", "/api"), "go test ./...", "npm test", "ls")
+	assertOrder(t, rankedOrder(t, "", "/web"), "npm test", "go test ./...", "ls")
+	// A prefix match outweighs the directory
+	assertOrder(t, rankedOrder(t, "ls", "/web"), "ls", "npm test", "go test ./...")
+}
+
+func TestFrecencyDecay(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "make", "", now, now.Add(-7*24*time.Hour), now.Add(-14*24*time.Hour))
+
+	ranked, err := GetRankedCommands(RankQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 + 1/2 + 1/4
+	if got := ranked[0].Frecency; math.Abs(got-1.75) > 0.001 {
+		t.Errorf("frecency = %.4f, want 1.75", got)
+	}
+}
+
+func TestFrecencyRebuiltOnHalfLifeChange(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "make", "", now.Add(-30*24*time.Hour))
+
+	config.Get().FrecencyHalfLifeDays = 30
+	if err := syncFrecency(); err != nil {
+		t.Fatal(err)
+	}
+
+	halfLife, err := storedHalfLife(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if halfLife != 30 {
+		t.Fatalf("stored half-life = %v, want 30", halfLife)
+	}
+	ranked, err := GetRankedCommands(RankQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ranked[0].Frecency; math.Abs(got-0.5) > 0.001 {
+		t.Errorf("frecency = %.4f, want 0.5", got)
+	}
+}
+
+func TestRankConsidersWholeHistory(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "deploy prod", "/ops", repeat(now.Add(-48*time.Hour), 50)...)
+	// Bury it under many more recent one-off commands
+	for i := 0; i < 300; i++ {
+		record(t, fmt.Sprintf("echo %d", i), "/tmp", now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	assertOrder(t, rankedOrder(t, "depl", "/tmp")[:1], "deploy prod")
+
+	found, err := SearchCommands([]string{"PROD"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].FullCommand != "deploy prod" {
+		t.Fatalf("search found %v, want deploy prod", found)
+	}
+}
+
+func TestRankFuzzy(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	for _, c := range []string{"ls", "vim logic_map.go", "git commit -m wip"} {
+		record(t, c, "/repo", repeat(now.Add(-time.Hour), 5)...)
+	}
+
+	// "gcm" is not a prefix of anything; the word starts of git commit -m
+	// match it better than the middle of logic_map
+	assertOrder(t, rankedOrder(t, "gcm", "/repo"), "git commit -m wip", "vim logic_map.go", "ls")
+
+	ranked, err := GetRankedCommands(RankQuery{Partial: "gcm", Directory: "/repo", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ranked[0].Positions); got != "[0 4 12]" {
+		t.Errorf("positions = %s, want [0 4 12]", got)
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "kubectl get pods", "", now)
+	record(t, "kubectl logs pod-gp", "", repeat(now, 10)...)
+	record(t, "kubectl describe node", "", repeat(now, 20)...)
+
+	found, err := SearchCommands([]string{"kgp"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The better match first despite being less frecent; no match is left out
+	if len(found) != 2 || found[0].FullCommand != "kubectl get pods" {
+		t.Fatalf("search found %v, want kubectl get pods first", found)
+	}
+	if got := fmt.Sprint(found[0].Positions); got != "[0 8 12]" {
+		t.Errorf("positions = %s, want [0 8 12]", got)
+	}
+}
+
+func TestSearchEscapesWildcards(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "echo 100%", "", now)
+	record(t, "echo 1000", "", now)
+	record(t, "rm a_b", "", now)
+	record(t, "rm axb", "", now)
+
+	for word, want := range map[string]string{"0%": "echo 100%", "a_b": "rm a_b"} {
+		found, err := SearchCommands([]string{word}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].FullCommand != want {
+			t.Errorf("search %q found %v, want %s", word, found, want)
+		}
+	}
+}
+
+func TestRankSameProject(t *testing.T) {
+	openTestDB(t, 7)
+	at := time.Now().Add(-time.Hour)
+	for _, r := range []struct{ command, dir, project string }{
+		{"make deploy", "/work/app", "github.com/me/app"},
+		{"npm start", "/work/web", "github.com/me/web"},
+	} {
+		if _, err := RecordRun(Run{
+			Base:        r.command,
+			FullCommand: r.command,
+			Directory:   r.dir,
+			Project:     r.project,
+			Execution:   Execution{Success: true, ExecutedAt: at},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second clone of app at another path shares its history
+	ranked, err := GetRankedCommands(RankQuery{Directory: "/tmp/app-clone/src", Project: "github.com/me/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranked[0].FullCommand != "make deploy" || ranked[0].Score <= ranked[1].Score {
+		t.Fatalf("ranked %v, want make deploy first", ranked)
+	}
+}
+
+func TestRankProjectType(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	for _, r := range []struct {
+		command, dir string
+		types        []string
+		at           time.Time
+	}{
+		{"go test ./...", "/src/api", []string{"go", "make"}, now.Add(-2 * time.Hour)},
+		{"npm test", "/src/web", []string{"node"}, now.Add(-time.Hour)},
+	} {
+		if _, err := RecordRun(Run{
+			Base:         r.command,
+			FullCommand:  r.command,
+			Directory:    r.dir,
+			ProjectTypes: r.types,
+			Execution:    Execution{Success: true, ExecutedAt: r.at},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Never worked in /src/cli, but it is a Go project
+	ranked, err := GetRankedCommands(RankQuery{Directory: "/src/cli", ProjectTypes: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrder(t, []string{ranked[0].FullCommand, ranked[1].FullCommand}, "go test ./...", "npm test")
+	assertOrder(t, ranked[0].ProjectTypes, "go", "make")
+}
+
+func TestRankFailures(t *testing.T) {
+	openTestDB(t, 7)
+	at := time.Now().Add(-time.Hour)
+	run := func(command string, success bool, n int) {
+		exitCode := 0
+		if !success {
+			exitCode = 1
+		}
+		for i := 0; i < n; i++ {
+			if _, err := RecordRun(Run{
+				Base:        command,
+				FullCommand: command,
+				Execution:   Execution{Success: success, ExitCode: exitCode, ExecutedAt: at},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	run("make tset", false, 5)
+	run("make test", true, 4)
+	run("make lint", true, 5)
+	run("make lint", false, 5)
+
+	// The typo never worked and is hidden; the flaky command ranks below the
+	// working one despite more runs
+	assertOrder(t, rankedOrder(t, "make", ""), "make test", "make lint")
+
+	ranked, err := GetRankedCommands(RankQuery{Partial: "make", IncludeFailed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranked) != 3 || ranked[2].FullCommand != "make tset" || !ranked[2].NeverSucceeded() {
+		t.Fatalf("ranked %v, want make tset last", ranked)
+	}
+	if got := ranked[1].FailureRate; math.Abs(got-0.5) > 0.001 {
+		t.Errorf("failure rate of make lint = %.3f, want 0.5", got)
+	}
+
+	// The recent and frequent lists of the shell integration hide it too
+	for _, list := range []func(string, int, bool) ([]Command, error){GetRecentByPrefix, GetFrecentByPrefix} {
+		for _, includeFailed := range []bool{false, true} {
+			commands, err := list("make", 3, includeFailed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, hidden := 2, true
+			if includeFailed {
+				want = 3
+			}
+			for _, c := range commands {
+				hidden = hidden && c.FullCommand != "make tset"
+			}
+			if len(commands) != want || hidden == includeFailed {
+				t.Errorf("includeFailed %v: got %v", includeFailed, commands)
+			}
+		}
+	}
+}
+
+func TestRankExplain(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	record(t, "git status", "/repo", repeat(now.Add(-time.Hour), 3)...)
+	record(t, "git stash", "/repo/sub", now.Add(-24*time.Hour))
+	record(t, "gcc main.c", "/tmp", now)
+
+	plain, err := GetRankedCommands(RankQuery{Partial: "gst", Directory: "/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	explained, err := GetRankedCommands(RankQuery{Partial: "gst", Directory: "/repo", Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explained) != len(plain) {
+		t.Fatalf("explained %d commands, ranked %d", len(explained), len(plain))
+	}
+
+	for i, c := range explained {
+		e := c.Explanation
+		if e == nil {
+			t.Fatalf("%s: no explanation", c.FullCommand)
+		}
+		if c.FullCommand != plain[i].FullCommand || math.Abs(e.Score-plain[i].Score) > 1e-6 {
+			t.Errorf("explained %s (%.4f), ranked %s (%.4f)", c.FullCommand, e.Score, plain[i].FullCommand, plain[i].Score)
+		}
+		var sum float64
+		for _, s := range e.Signals {
+			sum += s.Score
+		}
+		if math.Abs(sum-e.Score) > 1e-9 {
+			t.Errorf("%s: signals sum to %.4f, score %.4f", c.FullCommand, sum, e.Score)
+		}
+	}
+
+	status := explained[0].Explanation
+	if explained[0].FullCommand != "git status" || status.RunCount != 3 ||
+		status.DirectoryMatch != "directory" || status.Match != "fuzzy" {
+		t.Errorf("explanation of %s = %+v", explained[0].FullCommand, status)
+	}
+	for _, c := range explained {
+		if c.FullCommand == "git stash" && c.Explanation.DirectoryMatch != "related" {
+			t.Errorf("git stash directory match = %q, want related", c.Explanation.DirectoryMatch)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ScrubResult describes what Scrub redacted
+type ScrubResult struct {
+	// Commands are the redacted forms of the commands that held secrets
+	Commands []string
+	// Merged is the number of them that became identical to another
+	// command of the same directory and were merged into it
+	Merged int
+	// Notes and Transitions count the notes and next-command transitions
+	// that held secrets
+	Notes       int
+	Transitions int
+}
+
+// Changed reports whether anything was redacted
+func (r ScrubResult) Changed() bool {
+	return len(r.Commands) > 0 || r.Notes > 0 || r.Transitions > 0
+}
+
+// storedCommand is a command row as Scrub reads it
+type storedCommand struct {
+	id          int64
+	base        string
+	fullCommand string
+	directory   sql.NullString
+	note        string
+}
+
+// Scrub redacts the secrets in the stored history. redact returns a text
+// with its secrets replaced and whether it held any; parse re-parses a
+// redacted command into what is stored about it, like Reindex. Commands,
+// their segments, arguments and executions, notes and transitions are
+// rewritten, and the freed pages are vacuumed so the secrets do not linger
+// in the file. dryRun only reports what would be redacted.
+func Scrub(redact func(string) (string, bool), parse func(fullCommand string) (Run, bool), dryRun bool) (ScrubResult, error) {
+	var result ScrubResult
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, base, full_command, directory, note FROM commands ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	var commands []storedCommand
+	for rows.Next() {
+		var c storedCommand
+		if err := rows.Scan(&c.id, &c.base, &c.fullCommand, &c.directory, &c.note); err != nil {
+			rows.Close()
+			return result, err
+		}
+		commands = append(commands, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for _, c := range commands {
+		if note, ok := redact(c.note); ok {
+			result.Notes++
+			if !dryRun {
+				if _, err := tx.Exec("UPDATE commands SET note = ? WHERE id = ?", note, c.id); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		redacted, ok := redact(c.fullCommand)
+		if !ok {
+			continue
+		}
+		result.Commands = append(result.Commands, redacted)
+		if dryRun {
+			continue
+		}
+		run, ok := parse(redacted)
+		if !ok {
+			run = Run{Base: c.base, FullCommand: redacted}
+		}
+		merged, err := scrubCommand(tx, c, run)
+		if err != nil {
+			return result, fmt.Errorf("failed to scrub command %d: %w", c.id, err)
+		}
+		if merged {
+			result.Merged++
+		}
+	}
+
+	if result.Transitions, err = scrubTransitions(tx, redact, dryRun); err != nil {
+		return result, fmt.Errorf("failed to scrub transitions: %w", err)
+	}
+	if dryRun || !result.Changed() {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	// Merged commands add up the runs of both
+	if result.Merged > 0 {
+		halfLife, err := storedHalfLife(db)
+		if err != nil {
+			return result, err
+		}
+		if err := RebuildFrecency(halfLife); err != nil {
+			return result, err
+		}
+	}
+
+	// Rewrite the full-text index and the file without the old texts
+	for _, stmt := range []string{
+		"INSERT INTO commands_fts (commands_fts) VALUES ('optimize')",
+		"VACUUM",
+		"PRAGMA wal_checkpoint(TRUNCATE)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return result, fmt.Errorf("failed to compact the database: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// scrubCommand replaces a command with its redacted run. If the redacted
+// command already exists in the same directory, the command's executions
+// are moved to that one and the command is deleted; it reports whether it
+// was merged.
+func scrubCommand(tx *sql.Tx, c storedCommand, run Run) (bool, error) {
+	var target int64
+	err := tx.QueryRow("SELECT id FROM commands WHERE base = ? AND full_command = ? AND directory IS ? AND id <> ?",
+		run.Base, run.FullCommand, c.directory, c.id).Scan(&target)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec("UPDATE commands SET base = ?, subcommand = ?, full_command = ? WHERE id = ?",
+			run.Base, run.Subcommand, run.FullCommand, c.id); err != nil {
+			return false, err
+		}
+		if err := deleteParse(tx, c.id); err != nil {
+			return false, err
+		}
+		if err := addParse(tx, c.id, run); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec("UPDATE executions SET full_command = ? WHERE command_id = ?", run.FullCommand, c.id)
+		return false, err
+	case err != nil:
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE commands SET
+			frequency = commands.frequency + src.frequency,
+			successes = commands.successes + src.successes,
+			last_used = MAX(commands.last_used, src.last_used),
+			note = CASE WHEN commands.note = '' THEN src.note ELSE commands.note END
+		FROM (SELECT frequency, successes, last_used, note FROM commands WHERE id = ?) AS src
+		WHERE commands.id = ?
+	`, c.id, target); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("UPDATE executions SET command_id = ?, full_command = ? WHERE command_id = ?",
+		target, run.FullCommand, c.id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("UPDATE usage_stats SET command_id = ? WHERE command_id = ?", target, c.id); err != nil {
+		return false, err
+	}
+	if err := deleteParse(tx, c.id); err != nil {
+		return false, err
+	}
+	for _, stmt := range []string{
+		"DELETE FROM command_project_types WHERE command_id = ?",
+		"DELETE FROM commands WHERE id = ?",
+	} {
+		if _, err := tx.Exec(stmt, c.id); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// deleteParse removes what addParse stored for a command
+func deleteParse(q execer, commandID int64) error {
+	for _, table := range []string{"command_segments", "keywords", "flags", "command_args"} {
+		if _, err := q.Exec("DELETE FROM "+table+" WHERE command_id = ?", commandID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrubTransitions redacts the commands of transitions, adding up those
+// that become identical, and returns the number that held secrets
+func scrubTransitions(tx *sql.Tx, redact func(string) (string, bool), dryRun bool) (int, error) {
+	type transition struct {
+		prev, next, directory string
+		count                 int
+		lastAt                interface{}
+		// redactedPrev and redactedNext replace prev and next
+		redactedPrev, redactedNext string
+	}
+	rows, err := tx.Query("SELECT prev_command, next_command, directory, count, last_at FROM transitions")
+	if err != nil {
+		return 0, err
+	}
+	var secret []transition
+	for rows.Next() {
+		var t transition
+		if err := rows.Scan(&t.prev, &t.next, &t.directory, &t.count, &t.lastAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var prevSecret, nextSecret bool
+		t.redactedPrev, prevSecret = redact(t.prev)
+		t.redactedNext, nextSecret = redact(t.next)
+		if prevSecret || nextSecret {
+			secret = append(secret, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(secret), nil
+	}
+
+	for _, t := range secret {
+		if _, err := tx.Exec("DELETE FROM transitions WHERE prev_command = ? AND next_command = ? AND directory = ?",
+			t.prev, t.next, t.directory); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO transitions (prev_command, next_command, directory, count, last_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (prev_command, next_command, directory) DO UPDATE SET
+				count = count + excluded.count,
+				last_at = MAX(COALESCE(last_at, excluded.last_at), excluded.last_at)
+		`, t.redactedPrev, t.redactedNext, t.directory, t.count, t.lastAt); err != nil {
+			return 0, err
+		}
+	}
+	return len(secret), nil
+}
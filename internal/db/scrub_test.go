@@ -0,0 +1,74 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrub(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	for i, c := range []string{
+		"curl -H 'Authorization: Bearer abc' api",
+		"curl -H 'Authorization: Bearer xyz' api",
+		"curl -H 'Authorization: Bearer xyz' api",
+		"git status",
+	} {
+		if _, err := RecordRun(Run{
+			Base:        strings.Fields(c)[0],
+			FullCommand: c,
+			Directory:   "/repo",
+			Execution:   Execution{Success: true, SessionID: "s", ExecutedAt: now.Add(time.Duration(i) * time.Minute)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	redact := func(s string) (string, bool) {
+		for _, secret := range []string{"abc", "xyz"} {
+			if strings.Contains(s, secret) {
+				return strings.ReplaceAll(s, secret, "REDACTED"), true
+			}
+		}
+		return s, false
+	}
+	parse := func(fullCommand string) (Run, bool) {
+		return Run{Base: "curl", FullCommand: fullCommand}, true
+	}
+
+	result, err := Scrub(redact, parse, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Commands) != 2 || result.Merged != 0 {
+		t.Fatalf("dry run = %+v", result)
+	}
+	if result, err = Scrub(redact, parse, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Commands) != 2 || result.Merged != 1 || result.Transitions != 3 {
+		t.Fatalf("scrub = %+v", result)
+	}
+
+	// Both curl commands are now one, run three times
+	commands, err := GetTopCommands(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 2 || commands[0].FullCommand != "curl -H 'Authorization: Bearer REDACTED' api" ||
+		commands[0].Frequency != 3 {
+		t.Fatalf("commands = %+v", commands)
+	}
+	var executions, secrets int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(full_command LIKE '%abc%' OR full_command LIKE '%xyz%')
+		FROM executions WHERE command_id = ?`, commands[0].ID).Scan(&executions, &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if executions != 3 || secrets != 0 {
+		t.Errorf("executions = %d with %d secrets, want 3 and 0", executions, secrets)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM transitions WHERE prev_command LIKE '%xyz%' OR next_command LIKE '%abc%'").Scan(&secrets); err != nil || secrets != 0 {
+		t.Errorf("%d transitions still hold secrets (%v)", secrets, err)
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/fuzzy"
+)
+
+// TemplateUsage is how often the variants of a command template were run,
+// e.g. "kubectl logs {pod} -n {namespace}"
+type TemplateUsage struct {
+	Template string
+	// Variants is the number of distinct commands sharing the template
+	Variants int
+	RunCount int
+	// Latest is the most recently used variant
+	Latest   string
+	LastUsed time.Time
+}
+
+// GetTemplateUsage returns the most run templates shared by more than one
+// command
+func GetTemplateUsage(limit int) ([]TemplateUsage, error) {
+	rows, err := db.Query(`
+		SELECT template, COUNT(DISTINCT full_command) AS variants, SUM(frequency) AS total_runs,
+			full_command, MAX(last_used)
+		FROM commands
+		WHERE template <> full_command
+		GROUP BY template
+		HAVING variants > 1
+		ORDER BY total_runs DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []TemplateUsage
+	for rows.Next() {
+		var u TemplateUsage
+		var lastUsed string
+		if err := rows.Scan(&u.Template, &u.Variants, &u.RunCount, &u.Latest, &lastUsed); err != nil {
+			return nil, err
+		}
+		u.LastUsed, _ = parseTimestamp(lastUsed)
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// LatestVariants returns the usage of each of the templates that has
+// placeholders, keyed by template; Latest holds the values to fill them with
+func LatestVariants(templates []string) (map[string]TemplateUsage, error) {
+	variants := map[string]TemplateUsage{}
+	if len(templates) == 0 {
+		return variants, nil
+	}
+	args := make([]interface{}, len(templates))
+	for i, t := range templates {
+		args[i] = t
+	}
+
+	// The row of MAX(last_used) supplies full_command
+	rows, err := db.Query(`
+		SELECT template, COUNT(DISTINCT full_command), SUM(frequency), full_command, MAX(last_used)
+		FROM commands
+		WHERE template IN `+placeholders(len(args))+` AND template <> full_command
+		GROUP BY template
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u TemplateUsage
+		var lastUsed string
+		if err := rows.Scan(&u.Template, &u.Variants, &u.RunCount, &u.Latest, &lastUsed); err != nil {
+			return nil, err
+		}
+		u.LastUsed, _ = parseTimestamp(lastUsed)
+		variants[u.Template] = u
+	}
+	return variants, rows.Err()
+}
+
+// HasPlaceholders reports whether a command's template generalizes it
+func (c Command) HasPlaceholders() bool {
+	return c.Template != "" && c.Template != c.FullCommand
+}
+
+// collapseTemplates keeps only the best ranked command of each template
+// with placeholders and fills it with the values of the template's most
+// recently used variant, so near-duplicates such as "kubectl logs pod-a -n
+// prod" and "kubectl logs pod-b -n prod" make one suggestion. The latest
+// variant is only used if it still matches the partial as well.
+func collapseTemplates(ranked []RankedCommand, partial string) ([]RankedCommand, error) {
+	seen := map[string]bool{}
+	var templates []string
+	collapsed := ranked[:0]
+	for _, c := range ranked {
+		if !c.HasPlaceholders() {
+			collapsed = append(collapsed, c)
+			continue
+		}
+		if seen[c.Template] {
+			continue
+		}
+		seen[c.Template] = true
+		templates = append(templates, c.Template)
+		collapsed = append(collapsed, c)
+	}
+
+	variants, err := LatestVariants(templates)
+	if err != nil {
+		return nil, err
+	}
+	for i := range collapsed {
+		c := &collapsed[i]
+		v, ok := variants[c.Template]
+		if !ok {
+			continue
+		}
+		c.Variants = v.Variants
+		if v.Latest == c.FullCommand {
+			continue
+		}
+		switch {
+		case partial == "":
+			c.FullCommand, c.Positions = v.Latest, nil
+		case isPrefixMatch(*c, partial) && strings.HasPrefix(v.Latest, partial):
+			c.FullCommand = v.Latest
+			c.Positions, _ = partialPositions(partial, v.Latest)
+		case !isPrefixMatch(*c, partial) && c.Positions != nil:
+			if positions, ok := partialPositions(partial, v.Latest); ok {
+				c.FullCommand, c.Positions = v.Latest, positions
+			}
+		}
+	}
+	return collapsed, nil
+}
+
+// partialPositions fuzzy matches the partial against a command
+func partialPositions(partial, command string) ([]int, bool) {
+	m, ok := fuzzy.Find(partial, command)
+	return m.Positions, ok
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// recordTemplate stores a run of a command with its template
+func recordTemplate(t *testing.T, fullCommand, template string, at time.Time) {
+	t.Helper()
+	if _, err := RecordRun(Run{
+		Base:        "kubectl",
+		FullCommand: fullCommand,
+		Directory:   "/repo",
+		Template:    template,
+		Execution:   Execution{Success: true, ExecutedAt: at},
+	}); err != nil {
+		t.Fatalf("record %s: %v", fullCommand, err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	openTestDB(t, 7)
+	now := time.Now()
+	logs := "kubectl logs {pod} -n {namespace}"
+	// The oldest variant is run most, the newest supplies the values
+	for i := 0; i < 5; i++ {
+		recordTemplate(t, "kubectl logs api-1 -n prod", logs, now.Add(-3*time.Hour))
+	}
+	recordTemplate(t, "kubectl logs web-2 -n prod", logs, now.Add(-2*time.Hour))
+	recordTemplate(t, "kubectl logs web-3 -n dev", logs, now.Add(-time.Hour))
+	recordTemplate(t, "kubectl get pods", "", now)
+
+	usage, err := GetTemplateUsage(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0].Template != logs || usage[0].Variants != 3 || usage[0].RunCount != 7 ||
+		usage[0].Latest != "kubectl logs web-3 -n dev" {
+		t.Fatalf("template usage = %+v", usage)
+	}
+
+	ranked, err := GetRankedCommands(RankQuery{Partial: "kubectl logs", Directory: "/repo", Templates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range ranked {
+		got = append(got, c.FullCommand)
+	}
+	assertOrder(t, got, "kubectl logs web-3 -n dev", "kubectl get pods")
+	if ranked[0].Variants != 3 {
+		t.Errorf("variants = %d, want 3", ranked[0].Variants)
+	}
+
+	// A latest variant that does not start with what was typed is not used
+	ranked, err = GetRankedCommands(RankQuery{Partial: "kubectl logs api", Directory: "/repo", Templates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranked[0].FullCommand != "kubectl logs api-1 -n prod" {
+		t.Errorf("top suggestion for kubectl logs api = %s", ranked[0].FullCommand)
+	}
+}
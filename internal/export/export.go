@@ -3,10 +3,12 @@ package export
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
 )
 
 // ExportJSON exports commands to JSON
@@ -69,30 +71,61 @@ func ExportCSV(filename string) error {
 	return nil
 }
 
-// ImportJSON imports commands from JSON
-func ImportJSON(filename string) error {
+// ImportJSON imports commands from JSON. Every run goes through
+// tracker.Record as a tracked one does, so secrets are redacted before they
+// reach the database and commands an ignore rule matches are skipped. Nothing
+// is imported while the shell session is paused. It returns the number of
+// commands imported and skipped.
+func ImportJSON(filename string) (imported, skipped int, err error) {
+	session := os.Getenv("KWIK_CMD_SESSION")
+	pause, err := tracker.CurrentPause(session)
+	if err != nil {
+		return 0, 0, err
+	}
+	if pause.Active() {
+		return 0, 0, fmt.Errorf("not importing: tracking is %s", pause)
+	}
+
 	if err := db.Init(); err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return 0, 0, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return 0, 0, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	var commands []db.Command
 	if err := json.Unmarshal(data, &commands); err != nil {
-		return fmt.Errorf("failed to unmarshal: %w", err)
+		return 0, 0, fmt.Errorf("failed to unmarshal: %w", err)
 	}
 
-	// Re-import each command
-	for _, c := range commands {
-		_, err := db.AddCommand(c.Base, c.Subcommand, c.FullCommand, c.Directory)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to import %s: %v\n", c.FullCommand, err)
+	// Re-import each command, replaying one execution per recorded use so the
+	// aggregates can be derived from the execution log. Warnings name the
+	// position of a command, as the command itself may hold a secret.
+	for i, c := range commands {
+		runs := max(c.Frequency, 1)
+		for range runs {
+			_, err = tracker.Record(c.FullCommand, tracker.Execution{
+				Success:    true,
+				Directory:  c.Directory,
+				SessionID:  session,
+				ExecutedAt: c.LastUsed,
+			})
+			if err != nil {
+				break
+			}
+		}
+		switch {
+		case err == nil:
+			imported++
+		case errors.Is(err, tracker.ErrIgnored):
+			skipped++
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: failed to import command %d: %v\n", i+1, err)
 		}
 	}
 
-	return nil
+	return imported, skipped, nil
 }
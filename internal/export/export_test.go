@@ -0,0 +1,115 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/tracker"
+)
+
+// setup points the config and database at a temporary directory
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("KWIK_CMD_SESSION", "")
+	t.Setenv(config.EnvConfigPath, filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.EnvDatabasePath, filepath.Join(dir, "commands.db"))
+	if _, err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writeExport writes commands as ExportJSON does
+func writeExport(t *testing.T, dir string, commands []db.Command) string {
+	t.Helper()
+	data, err := json.Marshal(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "export.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// stored returns the full command and frequency of every stored command
+func stored(t *testing.T) map[string]int {
+	t.Helper()
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.GetDB().Query("SELECT full_command, frequency FROM commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[string]int{}
+	for rows.Next() {
+		var cmd string
+		var frequency int
+		if err := rows.Scan(&cmd, &frequency); err != nil {
+			t.Fatal(err)
+		}
+		got[cmd] = frequency
+	}
+	return got
+}
+
+func TestImportJSONRedacts(t *testing.T) {
+	dir := setup(t)
+	path := writeExport(t, dir, []db.Command{
+		{FullCommand: "vault login --token=s.abcdef", Frequency: 2, LastUsed: time.Now().Add(-time.Hour), Directory: dir},
+		{FullCommand: "make build", Frequency: 3, LastUsed: time.Now().Add(-time.Hour), Directory: dir},
+	})
+	if _, _, err := ImportJSON(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got := stored(t)
+	if got["vault login --token=REDACTED"] != 2 || got["make build"] != 3 || len(got) != 2 {
+		t.Errorf("stored %v, want the token redacted and the frequencies kept", got)
+	}
+}
+
+func TestImportJSONSkipsIgnored(t *testing.T) {
+	dir := setup(t)
+	path := writeExport(t, dir, []db.Command{
+		{FullCommand: "cd ..", Frequency: 5, Directory: dir},
+		{FullCommand: " export TOKEN=abc", Frequency: 1, Directory: dir},
+		{FullCommand: "make build", Frequency: 1, Directory: dir},
+	})
+	imported, skipped, err := ImportJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 || skipped != 2 {
+		t.Errorf("imported %d, skipped %d, want 1 and 2", imported, skipped)
+	}
+	if got := stored(t); len(got) != 1 || got["make build"] != 1 {
+		t.Errorf("stored %v, want only make build", got)
+	}
+}
+
+func TestImportJSONWhilePaused(t *testing.T) {
+	dir := setup(t)
+	t.Setenv("KWIK_CMD_SESSION", "s1")
+	if _, err := tracker.Pause("s1", 0); err != nil {
+		t.Fatal(err)
+	}
+	path := writeExport(t, dir, []db.Command{{FullCommand: "make build", Frequency: 1, Directory: dir}})
+	if _, _, err := ImportJSON(path); err == nil {
+		t.Error("expected an error importing while paused")
+	}
+	if got := stored(t); len(got) != 0 {
+		t.Errorf("stored %v while paused", got)
+	}
+}
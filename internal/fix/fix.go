@@ -0,0 +1,217 @@
+// Package fix corrects commands that failed because of a typo ("gti
+// status", "kubectl get pdos") from the commands that have succeeded before.
+package fix
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
+)
+
+// Correctable reports whether line exiting with code may have been
+// mistyped: 127 is "command not found" and 2 and 64 (EX_USAGE) are the
+// conventional usage errors. 1 is what git, kubectl and most CLIs exit with
+// for an unknown subcommand, but also what grep and test exit with for no
+// match, so it only counts for tools with a grammar, whose subcommands are
+// known; the first argument of any other tool is not a subcommand to correct.
+func Correctable(exitCode int, line string) bool {
+	switch exitCode {
+	case 2, 64, 127:
+		return true
+	case 1:
+		parsed := parser.ParseCommand(strings.TrimSpace(line))
+		return parsed != nil && parser.LookupGrammar(parsed.Base) != nil
+	}
+	return false
+}
+
+// Correction is a corrected command line
+type Correction struct {
+	Command string
+	// Distance is the number of edits made to the base and subcommand
+	Distance int
+	// Successes is how often the corrected base and subcommand succeeded
+	Successes int
+
+	// words is the number of words corrected against
+	words int
+}
+
+// Suggest returns the corrections of a failed command line, best first,
+// from the commands in the open database that have succeeded
+func Suggest(line string, limit int) ([]Correction, error) {
+	heads, err := db.SucceededHeads()
+	if err != nil {
+		return nil, err
+	}
+	corrections := Correct(line, heads)
+	if limit > 0 && len(corrections) > limit {
+		corrections = corrections[:limit]
+	}
+	return corrections, nil
+}
+
+// Correct returns the corrections of line against known heads, best first.
+// The base and subcommand words of the line are compared with those of each
+// head; every word may be off by a few edits (see maxEdits), and the words
+// are replaced in place so flags and arguments are kept. Longer heads win
+// ("git status" over "git"), then fewer edits, then more successes. A line
+// whose own base and subcommand have succeeded is not a typo and gets none.
+func Correct(line string, heads []db.CommandHead) []Correction {
+	line = strings.TrimSpace(line)
+	parsed := parser.ParseCommand(line)
+	if parsed == nil || parsed.Base == "" {
+		return nil
+	}
+	for _, h := range heads {
+		if h.Base == parsed.Base && h.Subcommand == parsed.Subcommand {
+			return nil
+		}
+	}
+	words := positionalWords(parsed)
+	if len(words) == 0 {
+		return nil
+	}
+
+	best := map[string]Correction{}
+	for _, h := range heads {
+		want := append([]string{h.Base}, strings.Fields(h.Subcommand)...)
+		if len(want) > len(words) {
+			continue
+		}
+
+		distance := 0
+		for i, w := range want {
+			d := editDistance(words[i].Value, w)
+			if d > maxEdits(w) {
+				distance = -1
+				break
+			}
+			distance += d
+		}
+		if distance <= 0 {
+			continue
+		}
+
+		c := Correction{
+			Command:   replaceWords(line, words[:len(want)], want),
+			Distance:  distance,
+			Successes: h.Successes,
+			words:     len(want),
+		}
+		if prev, ok := best[c.Command]; !ok || better(c, prev) {
+			best[c.Command] = c
+		}
+	}
+
+	corrections := make([]Correction, 0, len(best))
+	for _, c := range best {
+		corrections = append(corrections, c)
+	}
+	sort.Slice(corrections, func(i, j int) bool {
+		a, b := corrections[i], corrections[j]
+		if better(a, b) || better(b, a) {
+			return better(a, b)
+		}
+		return a.Command < b.Command
+	})
+	return corrections
+}
+
+// better orders corrections: more words, then fewer edits, then more
+// successes
+func better(a, b Correction) bool {
+	if a.words != b.words {
+		return a.words > b.words
+	}
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+	return a.Successes > b.Successes
+}
+
+// maxEdits is how far a typed word may be from word: nothing for a single
+// character, one edit up to five characters and two beyond
+func maxEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 1:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// positionalWords returns the tokens of the first simple command of a
+// parsed line from its command word on that are not flags
+func positionalWords(parsed *parser.ParsedCommand) []parser.Token {
+	var words []parser.Token
+	started := false
+	for _, t := range parsed.Tokens {
+		if t.Type == parser.TokenOperator {
+			if started {
+				break
+			}
+			continue
+		}
+		if t.Type != parser.TokenWord {
+			continue
+		}
+		if !started {
+			// Skip assignments, wrappers and their options up to the command
+			if t.Value != parsed.Base && filepath.Base(t.Value) != parsed.Base {
+				continue
+			}
+			started = true
+		}
+		if !strings.HasPrefix(t.Value, "-") {
+			words = append(words, t)
+		}
+	}
+	return words
+}
+
+// replaceWords replaces the given tokens of line with replacements
+func replaceWords(line string, tokens []parser.Token, replacements []string) string {
+	// Back to front so earlier offsets stay valid
+	for i := len(tokens) - 1; i >= 0; i-- {
+		t := tokens[i]
+		if t.Value == replacements[i] {
+			continue
+		}
+		line = line[:t.Pos] + replacements[i] + line[t.Pos+len(t.Raw):]
+	}
+	return line
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of adjacent characters
+// ("gti" -> "git") each count as one edit
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// Three rows of the dynamic programming matrix: i-2, i-1 and i
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
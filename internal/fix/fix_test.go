@@ -0,0 +1,87 @@
+package fix
+
+import (
+	"testing"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"git", "git", 0},
+		{"gti", "git", 1},
+		{"pdos", "pods", 1},
+		{"stauts", "status", 1},
+		{"comit", "commit", 1},
+		{"dokcer", "docker", 1},
+		{"", "ls", 2},
+		{"make", "cargo", 4},
+	} {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	heads := []db.CommandHead{
+		{Base: "git", Successes: 3},
+		{Base: "git", Subcommand: "status", Successes: 40},
+		{Base: "git", Subcommand: "stash", Successes: 2},
+		{Base: "git", Subcommand: "commit", Successes: 25},
+		{Base: "kubectl", Subcommand: "get pods", Successes: 12},
+		{Base: "kubectl", Subcommand: "get", Successes: 4},
+		{Base: "apt-get", Subcommand: "install", Successes: 1},
+		{Base: "make", Subcommand: "test", Successes: 9},
+	}
+	for _, tc := range []struct {
+		line, want string
+	}{
+		{"gti status", "git status"},
+		{"git stauts -s", "git status -s"},
+		{"kubectl get pdos -n prod", "kubectl get pods -n prod"},
+		{"kubectl gte pods", "kubectl get pods"},
+		{`gti comit -m "wip"`, `git commit -m "wip"`},
+		{"sudo apt-get isntall vim", "sudo apt-get install vim"},
+		{"gti", "git"},
+		// Known commands failing for other reasons are not typos
+		{"make test", ""},
+		{"git status", ""},
+		// Too far from anything known
+		{"grep foo", ""},
+	} {
+		got := ""
+		if corrections := Correct(tc.line, heads); len(corrections) > 0 {
+			got = corrections[0].Command
+		}
+		if got != tc.want {
+			t.Errorf("Correct(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestCorrectable(t *testing.T) {
+	for _, tc := range []struct {
+		exitCode int
+		line     string
+		want     bool
+	}{
+		{127, "gti status", true},
+		{2, "grep -Q foo", true},
+		{64, "frobnicate spin", true},
+		// Exit 1 is a typo only for tools whose subcommands are known
+		{1, "git stauts", true},
+		{1, "sudo kubectl get pdos", true},
+		{1, "grep errors log.txt", false},
+		{1, "test -f missing", false},
+		{0, "gti status", false},
+		{130, "gti status", false},
+	} {
+		if got := Correctable(tc.exitCode, tc.line); got != tc.want {
+			t.Errorf("Correctable(%d, %q) = %v, want %v", tc.exitCode, tc.line, got, tc.want)
+		}
+	}
+}
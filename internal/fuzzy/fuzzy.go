@@ -0,0 +1,265 @@
+// Package fuzzy implements an fzf-style scored subsequence matcher.
+//
+// A pattern matches a text when its characters appear in the text in order.
+// Among all the ways they can be placed, the matcher picks the one with the
+// highest score: every matched character scores, characters at the start of
+// a word (after a space, a delimiter such as / or -, or a camelCase hump)
+// and runs of consecutive characters earn bonuses, and gaps between matched
+// characters cost a penalty that grows with their length.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scoring constants, following fzf
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// bonusBoundary is for a character at the start of a word
+	bonusBoundary = scoreMatch / 2
+	// bonusBoundaryWhite and bonusBoundaryDelimiter are for word starts
+	// after whitespace and after a path or list delimiter
+	bonusBoundaryWhite     = bonusBoundary + 2
+	bonusBoundaryDelimiter = bonusBoundary + 1
+	// bonusNonWord is for matching punctuation, which is as significant as a
+	// word start
+	bonusNonWord = scoreMatch / 2
+	// bonusCamel123 is for a lower-to-upper or letter-to-digit transition
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+	// bonusConsecutive makes a run of matched characters worth at least as
+	// much as avoiding a gap
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// bonusFirstCharMultiplier weighs where the match starts
+	bonusFirstCharMultiplier = 2
+)
+
+// Match is a successful match of a pattern in a text
+type Match struct {
+	Score int
+	// Positions are the indexes of the matched runes of the text, in order
+	Positions []int
+}
+
+// charClass groups characters by how they delimit words
+type charClass int
+
+const (
+	classWhite charClass = iota
+	classNonWord
+	classDelimiter
+	classLower
+	classUpper
+	classLetter
+	classNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return classLower
+	case r >= 'A' && r <= 'Z':
+		return classUpper
+	case r >= '0' && r <= '9':
+		return classNumber
+	case unicode.IsSpace(r):
+		return classWhite
+	case strings.ContainsRune("/,:;|", r):
+		return classDelimiter
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsNumber(r):
+		return classNumber
+	}
+	return classNonWord
+}
+
+// bonusFor returns the bonus for matching a character of class cur that
+// follows one of class prev
+func bonusFor(prev, cur charClass) int {
+	if cur > classDelimiter {
+		switch {
+		case prev == classWhite:
+			return bonusBoundaryWhite
+		case prev == classDelimiter:
+			return bonusBoundaryDelimiter
+		case prev == classNonWord:
+			return bonusBoundary
+		case prev == classLower && cur == classUpper,
+			prev != classNumber && cur == classNumber:
+			return bonusCamel123
+		}
+		return 0
+	}
+	if cur == classWhite {
+		return bonusBoundaryWhite
+	}
+	return bonusNonWord
+}
+
+// Find matches pattern as a subsequence of text, ignoring case like the
+// LIKE queries that fetch candidates from the history. An empty pattern
+// matches everything with score 0.
+func Find(pattern, text string) (Match, bool) {
+	if pattern == "" {
+		return Match{}, true
+	}
+	p := lower([]rune(pattern))
+	t := []rune(text)
+	folded := lower(t)
+	if !isSubsequence(p, folded) {
+		return Match{}, false
+	}
+
+	bonus := make([]int, len(t))
+	prev := classWhite
+	for j, r := range t {
+		cur := classOf(r)
+		bonus[j] = bonusFor(prev, cur)
+		prev = cur
+	}
+	return align(p, folded, bonus), true
+}
+
+// FindTerms matches each whitespace separated term of query as its own
+// pattern, in any order, the way fzf treats a query of several words. The
+// score is the sum of the terms' scores and the positions their union.
+func FindTerms(query, text string) (Match, bool) {
+	var m Match
+	for _, term := range strings.Fields(query) {
+		tm, ok := Find(term, text)
+		if !ok {
+			return Match{}, false
+		}
+		m.Score += tm.Score
+		m.Positions = mergePositions(m.Positions, tm.Positions)
+	}
+	return m, true
+}
+
+// PerfectScore is the score of pattern matched against itself, an upper
+// bound useful for normalizing scores to 0-1
+func PerfectScore(pattern string) int {
+	m, _ := Find(pattern, pattern)
+	return m.Score
+}
+
+// align finds the best placement of p in t by dynamic programming over
+// (pattern index, text index), like the Smith-Waterman alignment of fzf v2
+func align(p, t []rune, bonus []int) Match {
+	m, n := len(p), len(t)
+	const none = -1 << 30
+
+	// score[i][j] is the best score of p[:i+1] with p[i] matched at t[j];
+	// run[i][j] is the bonus of the consecutive run ending there and from
+	// the text index p[i-1] was matched at
+	score := make([][]int, m)
+	run := make([][]int, m)
+	from := make([][]int, m)
+	for i := range score {
+		score[i] = make([]int, n)
+		run[i] = make([]int, n)
+		from[i] = make([]int, n)
+		for j := range score[i] {
+			score[i][j] = none
+		}
+	}
+
+	for j := 0; j < n; j++ {
+		if t[j] == p[0] {
+			score[0][j] = scoreMatch + bonus[j]*bonusFirstCharMultiplier
+			run[0][j] = bonus[j]
+		}
+	}
+
+	for i := 1; i < m; i++ {
+		// gap is the best score of p[:i] matched before j-1, with the gap
+		// up to j already paid for, and gapFrom where p[i-1] was placed
+		gap, gapFrom := none, -1
+		for j := i; j < n; j++ {
+			if j >= 2 {
+				if gap != none {
+					gap += scoreGapExtension
+				}
+				if prev := score[i-1][j-2]; prev != none && prev+scoreGapStart > gap {
+					gap, gapFrom = prev+scoreGapStart, j-2
+				}
+			}
+			if t[j] != p[i] {
+				continue
+			}
+
+			best, bestFrom, bestRun := none, -1, bonus[j]
+			if prev := score[i-1][j-1]; prev != none {
+				// Consecutive: a run keeps the bonus of its first character
+				b := max(bonus[j], bonusConsecutive, run[i-1][j-1])
+				best, bestFrom, bestRun = prev+scoreMatch+b, j-1, b
+			}
+			if gap != none && gap+scoreMatch+bonus[j] > best {
+				best, bestFrom, bestRun = gap+scoreMatch+bonus[j], gapFrom, bonus[j]
+			}
+			score[i][j], from[i][j], run[i][j] = best, bestFrom, bestRun
+		}
+	}
+
+	end := -1
+	for j := m - 1; j < n; j++ {
+		if score[m-1][j] != none && (end < 0 || score[m-1][j] > score[m-1][end]) {
+			end = j
+		}
+	}
+
+	positions := make([]int, m)
+	for i, j := m-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return Match{Score: score[m-1][end], Positions: positions}
+}
+
+// isSubsequence reports whether p appears in t in order
+func isSubsequence(p, t []rune) bool {
+	i := 0
+	for _, r := range t {
+		if i < len(p) && r == p[i] {
+			i++
+		}
+	}
+	return i == len(p)
+}
+
+func lower(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+// mergePositions returns the sorted union of two sorted position lists
+func mergePositions(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			merged = append(merged, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			merged = append(merged, b[j])
+			j++
+		default:
+			merged = append(merged, a[i])
+			i++
+			j++
+		}
+	}
+	return merged
+}
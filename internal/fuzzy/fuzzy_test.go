@@ -0,0 +1,80 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPositions(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, text string
+		want          []int
+	}{
+		{"gcm", "git commit -m wip", []int{0, 4, 12}},
+		// Word starts beat an earlier scattered placement
+		{"dc", "docker compose up", []int{0, 7}},
+		// A consecutive run beats scattered characters
+		{"push", "git pull && git push", []int{16, 17, 18, 19}},
+		// camelCase humps count as word starts
+		{"gb", "getByID", []int{0, 3}},
+		{"GS", "git status", []int{0, 4}},
+		{"", "anything", nil},
+	} {
+		m, ok := Find(tc.pattern, tc.text)
+		if !ok {
+			t.Errorf("Find(%q, %q) did not match", tc.pattern, tc.text)
+			continue
+		}
+		if !reflect.DeepEqual(m.Positions, tc.want) {
+			t.Errorf("Find(%q, %q) positions = %v, want %v", tc.pattern, tc.text, m.Positions, tc.want)
+		}
+	}
+}
+
+func TestFindRejects(t *testing.T) {
+	for _, tc := range []struct{ pattern, text string }{
+		{"gti", "git status"},
+		{"xyz", "git status"},
+	} {
+		if m, ok := Find(tc.pattern, tc.text); ok {
+			t.Errorf("Find(%q, %q) = %+v, want no match", tc.pattern, tc.text, m)
+		}
+	}
+}
+
+func TestFindScoreOrder(t *testing.T) {
+	// Each pattern should score its texts in the given order
+	for _, tc := range []struct {
+		pattern string
+		texts   []string
+	}{
+		{"commit", []string{"git commit", "git recommit"}},
+		{"tf", []string{"terraform fmt", "git diff"}},
+		{"kgp", []string{"kubectl get pods", "kubectl logs pod-gp"}},
+	} {
+		prev := 1 << 30
+		for _, text := range tc.texts {
+			m, ok := Find(tc.pattern, text)
+			if !ok {
+				t.Fatalf("Find(%q, %q) did not match", tc.pattern, text)
+			}
+			if m.Score >= prev {
+				t.Errorf("Find(%q, %q) score %d, want below %d", tc.pattern, text, m.Score, prev)
+			}
+			prev = m.Score
+		}
+	}
+}
+
+func TestFindTerms(t *testing.T) {
+	m, ok := FindTerms("push git", "git push origin")
+	if !ok {
+		t.Fatal("terms in any order should match")
+	}
+	if want := []int{0, 1, 2, 4, 5, 6, 7}; !reflect.DeepEqual(m.Positions, want) {
+		t.Errorf("positions = %v, want %v", m.Positions, want)
+	}
+	if _, ok := FindTerms("push main", "git push origin"); ok {
+		t.Error("every term must match")
+	}
+}
@@ -0,0 +1,199 @@
+// Package ignore decides which command runs are not tracked: trivial
+// commands such as cd and ls, lines typed with a leading space, commands
+// matching user patterns and anything run in ignored directories.
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/parser"
+	"github.com/samber/lo"
+)
+
+// RegexpPrefix marks a pattern as a regular expression rather than a glob
+const RegexpPrefix = "re:"
+
+// Settings are the ignore rules of config
+type Settings struct {
+	// Commands are the base commands not tracked on their own
+	Commands []string
+	// Patterns are globs matched against the whole line, or regular
+	// expressions searched in it when they start with RegexpPrefix
+	Patterns []string
+	// Directories are globs of the directories, with their subdirectories,
+	// where nothing is tracked
+	Directories []string
+	// Space ignores lines typed with a leading space
+	Space bool
+	// MinLength and MaxLength bound the length of tracked lines in
+	// characters; 0 is no bound
+	MinLength int
+	MaxLength int
+}
+
+// Match describes the rule that ignores a command
+type Match struct {
+	// Rule is the config key of the rule, or "empty" and "kwik-cmd" for
+	// the built-in ones
+	Rule string
+	// Detail is what of the rule matched, e.g. the command or the pattern
+	Detail string
+}
+
+func (m Match) String() string {
+	if m.Detail == "" {
+		return m.Rule
+	}
+	return fmt.Sprintf("%s (%s)", m.Rule, m.Detail)
+}
+
+// pattern is a compiled glob or regular expression and how it was written
+type pattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// Rules are compiled Settings
+type Rules struct {
+	commands    map[string]bool
+	patterns    []pattern
+	directories []pattern
+	space       bool
+	minLength   int
+	maxLength   int
+}
+
+// New compiles the settings
+func New(s Settings) (*Rules, error) {
+	r := &Rules{
+		commands:  map[string]bool{},
+		space:     s.Space,
+		minLength: s.MinLength,
+		maxLength: s.MaxLength,
+	}
+	for _, c := range s.Commands {
+		r.commands[c] = true
+	}
+	for _, p := range s.Patterns {
+		var re *regexp.Regexp
+		if expr, ok := strings.CutPrefix(p, RegexpPrefix); ok {
+			var err error
+			if re, err = regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+			}
+		} else {
+			re = regexp.MustCompile("(?s)^" + globExpr(p, false) + "$")
+		}
+		r.patterns = append(r.patterns, pattern{p, re})
+	}
+	for _, d := range s.Directories {
+		expr := globExpr(filepath.Clean(expandHome(d)), true)
+		r.directories = append(r.directories, pattern{d, regexp.MustCompile("^" + expr + "$")})
+	}
+	return r, nil
+}
+
+// Match returns the first rule that ignores line run in directory, checked
+// in the order the rules are documented
+func (r *Rules) Match(line, directory string) (Match, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return Match{Rule: "empty"}, true
+	}
+	if r.space && (line[0] == ' ' || line[0] == '\t') {
+		return Match{Rule: "ignore_space"}, true
+	}
+
+	parsed := parser.ParseCommand(trimmed)
+	if parsed == nil {
+		return Match{Rule: "empty"}, true
+	}
+	// kwik-cmd's own commands are never tracked
+	if filepath.Base(parsed.Base) == "kwik-cmd" {
+		return Match{Rule: "kwik-cmd"}, true
+	}
+
+	length := utf8.RuneCountInString(trimmed)
+	if r.minLength > 0 && length < r.minLength {
+		return Match{Rule: "ignore_min_length", Detail: fmt.Sprintf("%d < %d characters", length, r.minLength)}, true
+	}
+	if r.maxLength > 0 && length > r.maxLength {
+		return Match{Rule: "ignore_max_length", Detail: fmt.Sprintf("%d > %d characters", length, r.maxLength)}, true
+	}
+
+	// A line is only ignored for its commands if it runs nothing else:
+	// "cd .. && ls" is, "cd src && make" is not
+	var bases []string
+	for _, seg := range parsed.Segments() {
+		base := filepath.Base(seg.Base)
+		if !r.commands[seg.Base] && !r.commands[base] {
+			bases = nil
+			break
+		}
+		bases = append(bases, base)
+	}
+	if len(bases) > 0 {
+		return Match{Rule: "ignore_commands", Detail: strings.Join(lo.Uniq(bases), ", ")}, true
+	}
+
+	for _, p := range r.patterns {
+		if p.re.MatchString(trimmed) {
+			return Match{Rule: "ignore_patterns", Detail: p.source}, true
+		}
+	}
+
+	if directory != "" {
+		for dir := filepath.Clean(directory); ; dir = filepath.Dir(dir) {
+			for _, p := range r.directories {
+				if p.re.MatchString(dir) {
+					return Match{Rule: "ignore_directories", Detail: p.source}, true
+				}
+			}
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// globExpr converts a glob to a regular expression. * and ? match any
+// characters in a command line; in a path they stop at a slash, which
+// only ** crosses.
+func globExpr(glob string, path bool) string {
+	var b strings.Builder
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && path && i+1 < len(runes) && runes[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*' && path:
+			b.WriteString("[^/]*")
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?' && path:
+			b.WriteString("[^/]")
+		case c == '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
@@ -0,0 +1,58 @@
+package ignore
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	r, err := New(Settings{
+		Commands:    []string{"cd", "ls", "exit"},
+		Patterns:    []string{"git commit -m *", "re:^(vim?|nano) "},
+		Directories: []string{"/home/me/private", "/tmp/**/scratch"},
+		Space:       true,
+		MinLength:   2,
+		MaxLength:   40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		line, dir string
+		rule      string
+	}{
+		{"", "/repo", "empty"},
+		{" export TOKEN=abc", "/repo", "ignore_space"},
+		{"kwik-cmd suggest git", "/repo", "kwik-cmd"},
+		{"w", "/repo", "ignore_min_length"},
+		{"echo aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "/repo", "ignore_max_length"},
+		{"cd ..", "/repo", "ignore_commands"},
+		{"cd .. && ls -la", "/repo", "ignore_commands"},
+		{"sudo /bin/ls /root", "/repo", "ignore_commands"},
+		{"git commit -m 'wip'", "/repo", "ignore_patterns"},
+		{"vi notes.txt", "/repo", "ignore_patterns"},
+		{"make build", "/home/me/private", "ignore_directories"},
+		{"make build", "/home/me/private/site/src", "ignore_directories"},
+		{"make build", "/tmp/a/b/scratch", "ignore_directories"},
+		// Tracked
+		{"cd src && make", "/repo", ""},
+		{"ls | wc -l", "/repo", ""},
+		{"git commit --amend", "/repo", ""},
+		{"vim-plug update", "/repo", ""},
+		{"make build", "/home/me/private2", ""},
+		{"make build", "/tmp/scratch-not", ""},
+	} {
+		m, ignored := r.Match(tt.line, tt.dir)
+		if ignored != (tt.rule != "") || m.Rule != tt.rule {
+			t.Errorf("Match(%q, %q) = %v, %v, want rule %q", tt.line, tt.dir, m, ignored, tt.rule)
+		}
+	}
+}
+
+func TestNewRejectsInvalidPatterns(t *testing.T) {
+	if _, err := New(Settings{Patterns: []string{"re:(unclosed"}}); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+	// Globs are always valid
+	if _, err := New(Settings{Patterns: []string{"[", "(*"}}); err != nil {
+		t.Error(err)
+	}
+}
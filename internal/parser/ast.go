@@ -0,0 +1,223 @@
+package parser
+
+// List is a sequence of pipelines joined by ;, &, && or ||
+type List struct {
+	Items []ListItem
+}
+
+// ListItem is a pipeline and the operator that follows it ("&&", "||", ";",
+// "&" or "" for the last item)
+type ListItem struct {
+	Pipeline *Pipeline
+	Op       string
+}
+
+// Pipeline is one or more commands joined by | or |&
+type Pipeline struct {
+	Commands []*Node
+	Negated  bool
+}
+
+// Node is a single element of a pipeline: either a simple command or a
+// subshell / brace group holding a nested list
+type Node struct {
+	Simple   *ParsedCommand
+	Subshell *List
+}
+
+// parseScript builds the command list for a whole command line
+func parseScript(input string, tokens []Token) *List {
+	p := &astParser{input: input, tokens: tokens}
+	return p.list("")
+}
+
+type astParser struct {
+	input  string
+	tokens []Token
+	pos    int
+}
+
+func (p *astParser) peek() *Token {
+	for p.pos < len(p.tokens) && p.tokens[p.pos].Type == TokenComment {
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// list parses pipelines until the input ends or the closing token of an
+// enclosing group ( ")" or "}" ) is reached
+func (p *astParser) list(closer string) *List {
+	l := &List{}
+	for {
+		t := p.peek()
+		if t == nil || closer != "" && isCloser(t, closer) {
+			return l
+		}
+
+		pipeline := p.pipeline(closer)
+		op := ""
+		if t := p.peek(); t != nil && t.Type == TokenOperator && isListOperator(t.Value) {
+			op = t.Value
+			p.pos++
+		} else if pipeline == nil && t != nil && !(closer != "" && isCloser(t, closer)) {
+			// Stray token (e.g. an unmatched ")"); skip it so parsing ends
+			p.pos++
+			continue
+		}
+
+		if pipeline != nil {
+			l.Items = append(l.Items, ListItem{Pipeline: pipeline, Op: op})
+		}
+	}
+}
+
+// pipeline parses commands joined by | or |&
+func (p *astParser) pipeline(closer string) *Pipeline {
+	pl := &Pipeline{}
+	if t := p.peek(); t != nil && t.Type == TokenWord && t.Raw == "!" {
+		pl.Negated = true
+		p.pos++
+	}
+
+	for {
+		if node := p.command(closer); node != nil {
+			pl.Commands = append(pl.Commands, node)
+		}
+		t := p.peek()
+		if t == nil || t.Type != TokenOperator || (t.Value != "|" && t.Value != "|&") {
+			break
+		}
+		p.pos++
+	}
+
+	if len(pl.Commands) == 0 {
+		return nil
+	}
+	return pl
+}
+
+// command parses a subshell, a brace group or a simple command
+func (p *astParser) command(closer string) *Node {
+	t := p.peek()
+	if t == nil {
+		return nil
+	}
+
+	if t.Type == TokenOperator && t.Value == "(" {
+		p.pos++
+		sub := p.list(")")
+		if t := p.peek(); t != nil && t.Value == ")" {
+			p.pos++
+		}
+		p.skipRedirects()
+		return &Node{Subshell: sub}
+	}
+	if t.Type == TokenWord && t.Raw == "{" {
+		p.pos++
+		sub := p.list("}")
+		if t := p.peek(); t != nil && t.Raw == "}" {
+			p.pos++
+		}
+		p.skipRedirects()
+		return &Node{Subshell: sub}
+	}
+
+	start := p.pos
+	for {
+		t := p.peek()
+		if t == nil || t.Type == TokenOperator || closer == "}" && isCloser(t, closer) {
+			break
+		}
+		p.pos++
+	}
+
+	var tokens []Token
+	for _, t := range p.tokens[start:p.pos] {
+		if t.Type != TokenComment {
+			tokens = append(tokens, t)
+		}
+	}
+	simple := parseSimpleCommand(tokens)
+	if simple == nil {
+		return nil
+	}
+	last := tokens[len(tokens)-1]
+	simple.FullCmd = p.input[tokens[0].Pos : last.Pos+len(last.Raw)]
+	simple.Tokens = tokens
+	return &Node{Simple: simple}
+}
+
+// skipRedirects skips redirections applied to a whole group
+func (p *astParser) skipRedirects() {
+	for {
+		t := p.peek()
+		if t == nil || t.Type != TokenRedirect {
+			return
+		}
+		p.pos++
+		if t := p.peek(); t != nil && t.Type == TokenWord {
+			p.pos++
+		}
+	}
+}
+
+func isCloser(t *Token, closer string) bool {
+	if closer == ")" {
+		return t.Type == TokenOperator && t.Value == ")"
+	}
+	return t.Type == TokenWord && t.Raw == closer
+}
+
+func isListOperator(op string) bool {
+	switch op {
+	case "&&", "||", ";", ";;", "&":
+		return true
+	}
+	return false
+}
+
+// Segments returns every simple command in the list in the order written,
+// descending into subshells and groups
+func (l *List) Segments() []*ParsedCommand {
+	var segments []*ParsedCommand
+	l.Walk(func(seg *ParsedCommand, op string) {
+		segments = append(segments, seg)
+	})
+	return segments
+}
+
+// Walk calls fn for every simple command in the order written, together with
+// the operator that follows it ("|", "&&", ...; "" for the last one). A
+// command closing a subshell is followed by the subshell's own operator.
+func (l *List) Walk(fn func(seg *ParsedCommand, op string)) {
+	l.walk(fn, "")
+}
+
+func (l *List) walk(fn func(seg *ParsedCommand, op string), trailing string) {
+	if l == nil {
+		return
+	}
+	for i, item := range l.Items {
+		for j, node := range item.Pipeline.Commands {
+			op := item.Op
+			if j < len(item.Pipeline.Commands)-1 {
+				op = "|"
+			} else if op == "" && i == len(l.Items)-1 {
+				op = trailing
+			}
+			if node.Simple != nil {
+				fn(node.Simple, op)
+			} else {
+				node.Subshell.walk(fn, op)
+			}
+		}
+	}
+}
+
+// IsCompound reports whether the list holds more than one simple command
+func (l *List) IsCompound() bool {
+	return len(l.Segments()) > 1
+}
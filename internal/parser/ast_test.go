@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// render writes a list back out with one space between its parts, each
+// simple command as its FullCmd in brackets and each subshell in parentheses
+func render(l *List) string {
+	var parts []string
+	for _, item := range l.Items {
+		var commands []string
+		for _, node := range item.Pipeline.Commands {
+			if node.Simple != nil {
+				commands = append(commands, "["+node.Simple.FullCmd+"]")
+			} else {
+				commands = append(commands, "( "+render(node.Subshell)+" )")
+			}
+		}
+		pipeline := strings.Join(commands, " | ")
+		if item.Pipeline.Negated {
+			pipeline = "! " + pipeline
+		}
+		parts = append(parts, pipeline)
+		if item.Op != "" {
+			parts = append(parts, item.Op)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseScript(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+	}{
+		{"git status", "[git status]"},
+		{"ps aux | grep nginx | wc -l", "[ps aux] | [grep nginx] | [wc -l]"},
+		{"make 2>&1 |& tee log", "[make 2>&1] | [tee log]"},
+		{"make && make install", "[make] && [make install]"},
+		{"make || echo failed", "[make] || [echo failed]"},
+		{"cd src; make; cd ..", "[cd src] ; [make] ; [cd ..]"},
+		{"sleep 5 &", "[sleep 5] &"},
+		{"git pull && make || echo 'no luck'", "[git pull] && [make] || [echo 'no luck']"},
+		{"! grep -q x file && echo missing", "! [grep -q x file] && [echo missing]"},
+		{"(cd src && make) && ls", "( [cd src] && [make] ) && [ls]"},
+		{"(cd src; (make | tee log)) > out", "( [cd src] ; ( [make] | [tee log] ) )"},
+		{"{ echo a; echo b; } | sort", "( [echo a] ; [echo b] ; ) | [sort]"},
+		{"git log | (head -1; tail -1)", "[git log] | ( [head -1] ; [tail -1] )"},
+		{"make # build", "[make]"},
+		{"make )", "[make]"},
+		{"", ""},
+	} {
+		tokens, err := Tokenize(tt.input)
+		if err != nil {
+			t.Fatalf("Tokenize(%q): %v", tt.input, err)
+		}
+		if got := render(parseScript(tt.input, tokens)); got != tt.want {
+			t.Errorf("parseScript(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+	}{
+		{"a | b && c", "a| b&& c"},
+		{"(a; b) || c", "a; b|| c"},
+		{"x | (a && b) ; c", "x| a&& b; c"},
+		{"a && (b | c)", "a&& b| c"},
+	} {
+		var got []string
+		ParseCommand(tt.input).Script.Walk(func(seg *ParsedCommand, op string) {
+			got = append(got, seg.FullCmd+op)
+		})
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Walk(%q) = %q, want %q", tt.input, strings.Join(got, " "), tt.want)
+		}
+	}
+
+	if !ParseCommand("a | b").Script.IsCompound() || ParseCommand("(a)").Script.IsCompound() {
+		t.Error("IsCompound counts simple commands, not groups")
+	}
+}
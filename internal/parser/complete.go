@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// ArgValue is a value given on a command line together with the slot it
+// fills, e.g. prod for -n of "kubectl get pods" or main for the first
+// argument of "git checkout". Words of a subcommand path the grammar does
+// not list, such as pods in "kubectl get pods", fill the first argument
+// slot of the path before them.
+type ArgValue struct {
+	Subcommand string
+	// Flag is the flag the value was given with, "" for an argument
+	Flag string
+	// Position is the index of an argument among the arguments, 0 for a
+	// flag value
+	Position int
+	Value    string
+}
+
+// ArgSlot is where a value is being typed on a command line, such as the
+// namespace after "kubectl -n " or the branch after "git checkout "
+type ArgSlot struct {
+	Base       string
+	Subcommand string
+	// Flag is the flag whose value is typed, "" for an argument, and
+	// Aliases are the flags taking the same kind of value, Flag included,
+	// e.g. -n and --namespace
+	Flag    string
+	Aliases []string
+	// Position is the index of the argument typed
+	Position int
+	// Name is what the grammar calls the value (namespace, branch, ...)
+	Name string
+	// Prefix is the part of the value typed so far
+	Prefix string
+	// Start is the byte offset of the value in the line, where a
+	// completion replaces Prefix
+	Start int
+}
+
+// CompletionSlot returns the slot the cursor, a byte offset into line, is
+// typing a value into. It returns false where no value is expected: in the
+// command name, in a flag, or after a redirection.
+func CompletionSlot(line string, cursor int) (ArgSlot, bool) {
+	if cursor < 0 || cursor > len(line) {
+		cursor = len(line)
+	}
+	line = line[:cursor]
+	tokens, _ := Tokenize(line)
+
+	// The word under the cursor is the typed part of the value
+	slot := ArgSlot{Start: len(line)}
+	var current *Token
+	if n := len(tokens); n > 0 && tokens[n-1].Pos+len(tokens[n-1].Raw) == len(line) {
+		switch tokens[n-1].Type {
+		case TokenWord, TokenAssignment:
+			current = &tokens[n-1]
+			slot.Prefix, slot.Start = current.Value, current.Pos
+			tokens = tokens[:n-1]
+		case TokenComment:
+			return ArgSlot{}, false
+		}
+	}
+
+	// Only the simple command the cursor is in matters
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Type == TokenOperator {
+			tokens = tokens[i+1:]
+			break
+		}
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].Type == TokenRedirect {
+		return ArgSlot{}, false
+	}
+
+	p := parseSimpleCommand(tokens)
+	if p == nil || len(p.Env) > 0 && p.Base == p.Env[0] {
+		return ArgSlot{}, false
+	}
+	slot.Base, slot.Subcommand = p.Base, p.Subcommand
+
+	switch {
+	case current != nil && !current.Quoted && strings.HasPrefix(current.Value, "-"):
+		// --flag=value; anything else starting with - is a flag being typed
+		flag, value, ok := strings.Cut(current.Value, "=")
+		if !ok || !strings.HasPrefix(flag, "--") {
+			return ArgSlot{}, false
+		}
+		slot.Flag, slot.Prefix = flag, value
+		slot.Start = current.Pos + strings.Index(current.Raw, "=") + 1
+	case p.pendingFlag != "":
+		slot.Flag = p.pendingFlag
+	default:
+		slot.Position = len(p.Args)
+		slot.Name = argName(p.grammarPath, slot.Position)
+		return slot, true
+	}
+
+	slot.Name = valueName(p.grammarPath, slot.Flag)
+	if slot.Name == "" {
+		slot.Name = longValueNames[slot.Flag]
+	}
+	slot.Aliases = flagAliases(p.grammarPath, slot.Flag, slot.Name)
+	return slot, true
+}
+
+// flagAliases returns flag and the flags along the grammar path whose value
+// has the same name, e.g. --namespace for -n of kubectl
+func flagAliases(path []*Grammar, flag, name string) []string {
+	aliases := []string{flag}
+	if name == "" {
+		return aliases
+	}
+	for _, g := range path {
+		for f, n := range g.ValueNames {
+			if n == name && !lo.Contains(aliases, f) {
+				aliases = append(aliases, f)
+			}
+		}
+	}
+	sort.Strings(aliases[1:])
+	return aliases
+}
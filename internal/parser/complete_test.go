@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompletionSlot(t *testing.T) {
+	for line, want := range map[string]ArgSlot{
+		"kubectl -n ": {Base: "kubectl", Flag: "-n", Aliases: []string{"-n", "--namespace"},
+			Name: "namespace", Start: 11},
+		"kubectl get pods -n pr": {Base: "kubectl", Subcommand: "get pods", Flag: "-n",
+			Aliases: []string{"-n", "--namespace"}, Name: "namespace", Prefix: "pr", Start: 20},
+		"kubectl logs --namespace=de": {Base: "kubectl", Subcommand: "logs", Flag: "--namespace",
+			Aliases: []string{"--namespace", "-n"}, Name: "namespace", Prefix: "de", Start: 25},
+		"git checkout ":            {Base: "git", Subcommand: "checkout", Name: "branch", Start: 13},
+		"git push origin ma":       {Base: "git", Subcommand: "push", Position: 1, Name: "branch", Prefix: "ma", Start: 16},
+		"make test && ssh dep":     {Base: "ssh", Prefix: "dep", Start: 17},
+		"sudo systemctl restart n": {Base: "systemctl", Subcommand: "restart", Name: "unit", Prefix: "n", Start: 23},
+	} {
+		got, ok := CompletionSlot(line, len(line))
+		if !ok {
+			t.Errorf("CompletionSlot(%q) found no slot", line)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CompletionSlot(%q) = %+v, want %+v", line, got, want)
+		}
+	}
+
+	// No value is typed in the command name, a flag or a redirection target
+	for _, line := range []string{"", "kub", "kubectl -", "git commit --am", "make > ", "ls # "} {
+		if got, ok := CompletionSlot(line, len(line)); ok {
+			t.Errorf("CompletionSlot(%q) = %+v, want none", line, got)
+		}
+	}
+
+	// Only the line before the cursor counts
+	if got, _ := CompletionSlot("git checkout  && make", 13); got.Subcommand != "checkout" || got.Prefix != "" {
+		t.Errorf("slot before the cursor = %+v", got)
+	}
+}
+
+func TestValues(t *testing.T) {
+	got := ParseCommand("kubectl -n prod get pods web-1 --context=staging").Values
+	want := []ArgValue{
+		{Subcommand: "get pods", Flag: "-n", Value: "prod"},
+		{Subcommand: "get", Value: "pods"},
+		{Subcommand: "get pods", Value: "web-1"},
+		{Subcommand: "get pods", Flag: "--context", Value: "staging"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %+v, want %+v", got, want)
+	}
+}
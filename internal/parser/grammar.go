@@ -0,0 +1,90 @@
+package parser
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// Grammar describes a command or subcommand: which of its flags take a value
+// and which subcommands it has
+type Grammar struct {
+	ValueFlags  []string            `yaml:"value_flags"`
+	Subcommands map[string]*Grammar `yaml:"subcommands"`
+	// ValueNames names what the values of flags are, e.g. namespace for -n,
+	// and Args the positional arguments in order; both name the
+	// placeholders of templates (see template.go)
+	ValueNames map[string]string `yaml:"value_names"`
+	Args       []string          `yaml:"args"`
+	// Depth is the number of further words that belong to the subcommand
+	// path even if they are not listed in Subcommands. It defaults to 1 for
+	// a tool and 0 for a subcommand.
+	Depth *int `yaml:"depth"`
+}
+
+//go:embed grammars.yaml
+var grammarsYAML []byte
+
+var (
+	grammarsOnce sync.Once
+	grammars     map[string]*Grammar
+)
+
+// LookupGrammar returns the grammar of a tool, or nil if it is not known
+func LookupGrammar(base string) *Grammar {
+	grammarsOnce.Do(func() {
+		if err := yaml.Unmarshal(grammarsYAML, &grammars); err != nil {
+			panic(fmt.Sprintf("invalid built-in grammars.yaml: %v", err))
+		}
+	})
+	return grammars[base]
+}
+
+// takesValue reports whether flag takes a separate value anywhere along the
+// subcommand path walked so far
+func takesValue(path []*Grammar, flag string) bool {
+	for _, g := range path {
+		if lo.Contains(g.ValueFlags, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueName returns the name of the value of flag along the subcommand path
+// walked so far, the innermost grammar first
+func valueName(path []*Grammar, flag string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if name := path[i].ValueNames[flag]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// argName returns the name of the n-th positional argument of the
+// innermost grammar on the path, or "" if it is not named
+func argName(path []*Grammar, n int) string {
+	if len(path) == 0 {
+		return ""
+	}
+	args := path[len(path)-1].Args
+	if n < len(args) {
+		return args[n]
+	}
+	return ""
+}
+
+// depth returns how many unlisted words may follow g in the subcommand path
+func (g *Grammar) depth(tool bool) int {
+	if g.Depth != nil {
+		return *g.Depth
+	}
+	if tool {
+		return 1
+	}
+	return 0
+}
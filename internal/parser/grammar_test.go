@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGrammarsYAML(t *testing.T) {
+	// Strict decoding catches misspelt keys, which would otherwise be dropped
+	var tools map[string]*Grammar
+	decoder := yaml.NewDecoder(bytes.NewReader(grammarsYAML))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&tools); err != nil {
+		t.Fatalf("grammars.yaml: %v", err)
+	}
+	if len(tools) == 0 {
+		t.Fatal("grammars.yaml holds no tools")
+	}
+
+	// Every named flag value belongs to a flag that takes one on the path
+	var check func(name string, path []*Grammar)
+	check = func(name string, path []*Grammar) {
+		g := path[len(path)-1]
+		for flag := range g.ValueNames {
+			if !takesValue(path, flag) {
+				t.Errorf("%s: value_names has %s, which is not in value_flags", name, flag)
+			}
+		}
+		for sub, child := range g.Subcommands {
+			if child == nil {
+				t.Errorf("%s %s: empty grammar, write {}", name, sub)
+				continue
+			}
+			check(name+" "+sub, append(path[:len(path):len(path)], child))
+		}
+	}
+	for name, g := range tools {
+		if g == nil {
+			t.Errorf("%s: empty grammar, write {}", name)
+			continue
+		}
+		check(name, []*Grammar{g})
+	}
+
+	if LookupGrammar("git") == nil || LookupGrammar("git").Subcommands["remote"] == nil {
+		t.Error("LookupGrammar(git) is missing its subcommands")
+	}
+	if LookupGrammar("no-such-tool") != nil {
+		t.Error("LookupGrammar of an unknown tool is not nil")
+	}
+}
+
+func TestSubcommandPath(t *testing.T) {
+	for _, tt := range []struct {
+		input, subcommand string
+	}{
+		{"git status", "status"},
+		{"git remote add origin git@host:repo.git", "remote add"},
+		{"git -C ~/src/api stash pop", "stash pop"},
+		{"git commit -m 'remote add' file.go", "commit"},
+		{"docker compose -f dev.yml up -d", "compose up"},
+		{"docker-compose logs -f web", "logs"},
+		{"kubectl -n prod get pods web-1", "get pods"},
+		{"kubectl logs -f web-1", "logs"},
+		{"npm run build", "run build"},
+		{"aws s3 cp ./dist s3://bucket", "s3 cp"},
+		{"aws --region eu-west-1 ec2 describe-instances", "ec2 describe-instances"},
+		{"gcloud compute instances list --zone x", "compute instances list"},
+		{"helm install web ./chart -n prod", "install"},
+		// Tools without a grammar take their first word
+		{"frobnicate spin fast", "spin"},
+		{"make", ""},
+	} {
+		if got := ParseCommand(tt.input).Subcommand; got != tt.subcommand {
+			t.Errorf("ParseCommand(%q).Subcommand = %q, want %q", tt.input, got, tt.subcommand)
+		}
+	}
+
+	// Value flags of the tool apply along the whole path
+	cmd := ParseCommand("kubectl get pods -n prod -l app=web api-1")
+	if cmd.FlagValues["-n"] != "prod" || cmd.FlagValues["-l"] != "app=web" || len(cmd.Args) != 1 || cmd.Args[0] != "api-1" {
+		t.Errorf("kubectl flags = %v, args = %v", cmd.FlagValues, cmd.Args)
+	}
+}
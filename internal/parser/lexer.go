@@ -0,0 +1,375 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+)
+
+// TokenType classifies a lexed shell token
+type TokenType int
+
+const (
+	// TokenWord is an ordinary word: a command name, flag or argument
+	TokenWord TokenType = iota
+	// TokenAssignment is a NAME=value prefix before the command word
+	TokenAssignment
+	// TokenOperator is a control operator: | || |& && & ; ( ) or a newline
+	TokenOperator
+	// TokenRedirect is a redirection operator such as > >> < 2> &> or <<<
+	TokenRedirect
+	// TokenComment is a # comment running to the end of the line
+	TokenComment
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenWord:
+		return "word"
+	case TokenAssignment:
+		return "assignment"
+	case TokenOperator:
+		return "operator"
+	case TokenRedirect:
+		return "redirect"
+	case TokenComment:
+		return "comment"
+	}
+	return "unknown"
+}
+
+// Token is a single lexical unit of a shell command line
+type Token struct {
+	Type TokenType
+	// Value is the word with quotes and escapes removed. Substitutions such
+	// as $(...), `...` and ${...} are kept verbatim; nothing is expanded.
+	Value string
+	// Raw is the token exactly as typed
+	Raw string
+	// Pos is the byte offset of the token in the input
+	Pos int
+	// Quoted reports whether any part of the word was quoted or escaped
+	Quoted bool
+}
+
+// ErrUnterminated is returned when a quote or substitution is not closed.
+// The tokens returned alongside it are still usable.
+var ErrUnterminated = errors.New("unterminated quote or substitution")
+
+// operators lists control and redirection operators, longest first so the
+// lexer always takes the longest match
+var operators = []struct {
+	text string
+	typ  TokenType
+}{
+	{"&>>", TokenRedirect},
+	{"<<<", TokenRedirect},
+	{"&&", TokenOperator},
+	{"||", TokenOperator},
+	{"|&", TokenOperator},
+	{";;", TokenOperator},
+	{"&>", TokenRedirect},
+	{">>", TokenRedirect},
+	{">&", TokenRedirect},
+	{">|", TokenRedirect},
+	{"<<", TokenRedirect},
+	{"<&", TokenRedirect},
+	{"<>", TokenRedirect},
+	{"|", TokenOperator},
+	{"&", TokenOperator},
+	{";", TokenOperator},
+	{"(", TokenOperator},
+	{")", TokenOperator},
+	{">", TokenRedirect},
+	{"<", TokenRedirect},
+}
+
+// Tokenize splits a command line into shell tokens following POSIX quoting
+// rules: single quotes, double quotes, backslash escapes, $(...), `...`,
+// ${...} and # comments. Leading NAME=value words of each simple command
+// are reported as assignments.
+func Tokenize(input string) ([]Token, error) {
+	l := &lexer{input: input, commandStart: true}
+	for l.pos < len(l.input) {
+		l.next()
+	}
+	return l.tokens, l.err
+}
+
+type lexer struct {
+	input  string
+	pos    int
+	tokens []Token
+	err    error
+	// commandStart is true while no command word has been seen in the
+	// current simple command, i.e. assignments are still possible
+	commandStart bool
+}
+
+func (l *lexer) emit(t Token) {
+	switch t.Type {
+	case TokenWord:
+		if l.commandStart && isAssignment(t.Raw) {
+			t.Type = TokenAssignment
+		} else {
+			l.commandStart = false
+		}
+	case TokenOperator:
+		l.commandStart = true
+	}
+	l.tokens = append(l.tokens, t)
+}
+
+// next lexes one token (or skips blanks) starting at l.pos
+func (l *lexer) next() {
+	c := l.input[l.pos]
+
+	switch {
+	case c == ' ' || c == '\t':
+		l.pos++
+		return
+	case c == '\\' && strings.HasPrefix(l.input[l.pos+1:], "\n"):
+		// A line continuation between words is a blank
+		l.pos += 2
+		return
+	case c == '\n':
+		l.emit(Token{Type: TokenOperator, Value: ";", Raw: "\n", Pos: l.pos})
+		l.pos++
+		return
+	case c == '#':
+		end := strings.IndexByte(l.input[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.input) - l.pos
+		}
+		text := l.input[l.pos : l.pos+end]
+		l.tokens = append(l.tokens, Token{Type: TokenComment, Value: text, Raw: text, Pos: l.pos})
+		l.pos += end
+		return
+	}
+
+	if op, typ, ok := l.operatorAt(l.pos); ok {
+		l.emit(Token{Type: typ, Value: op, Raw: op, Pos: l.pos})
+		l.pos += len(op)
+		return
+	}
+
+	l.word()
+}
+
+// operatorAt reports the operator starting at i, if any
+func (l *lexer) operatorAt(i int) (string, TokenType, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[i:], op.text) {
+			return op.text, op.typ, true
+		}
+	}
+	return "", 0, false
+}
+
+// word lexes a word, handling quotes, escapes and substitutions
+func (l *lexer) word() {
+	start := l.pos
+	var value strings.Builder
+	quoted := false
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+
+		if c == ' ' || c == '\t' || c == '\n' {
+			break
+		}
+		if _, _, ok := l.operatorAt(l.pos); ok {
+			// A run of digits directly before a redirection is its fd (2>)
+			if (c == '>' || c == '<') && value.Len() > 0 && !quoted && isDigits(l.input[start:l.pos]) {
+				l.redirectWithFD(start)
+				return
+			}
+			break
+		}
+
+		switch c {
+		case '\\':
+			quoted = true
+			if l.pos+1 >= len(l.input) {
+				l.pos++
+				continue
+			}
+			if l.input[l.pos+1] != '\n' { // backslash-newline is a line continuation
+				value.WriteByte(l.input[l.pos+1])
+			}
+			l.pos += 2
+		case '\'':
+			quoted = true
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				value.WriteString(l.input[l.pos+1:])
+				l.pos = len(l.input)
+				l.err = ErrUnterminated
+				continue
+			}
+			value.WriteString(l.input[l.pos+1 : l.pos+1+end])
+			l.pos += end + 2
+		case '"':
+			quoted = true
+			l.doubleQuoted(&value)
+		case '$':
+			value.WriteString(l.substitution())
+		case '`':
+			value.WriteString(l.backquoted())
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	l.emit(Token{Type: TokenWord, Value: value.String(), Raw: l.input[start:l.pos], Pos: start, Quoted: quoted})
+}
+
+// redirectWithFD emits a redirection such as 2> or 2>&1's operator part
+func (l *lexer) redirectWithFD(start int) {
+	op, _, _ := l.operatorAt(l.pos)
+	l.pos += len(op)
+	raw := l.input[start:l.pos]
+	l.emit(Token{Type: TokenRedirect, Value: raw, Raw: raw, Pos: start})
+}
+
+// doubleQuoted consumes a "..." string starting at l.pos
+func (l *lexer) doubleQuoted(value *strings.Builder) {
+	l.pos++ // opening quote
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return
+		case '\\':
+			if l.pos+1 < len(l.input) && strings.IndexByte("$`\"\\\n", l.input[l.pos+1]) >= 0 {
+				if l.input[l.pos+1] != '\n' {
+					value.WriteByte(l.input[l.pos+1])
+				}
+				l.pos += 2
+				continue
+			}
+			value.WriteByte(c)
+			l.pos++
+		case '$':
+			value.WriteString(l.substitution())
+		case '`':
+			value.WriteString(l.backquoted())
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+	l.err = ErrUnterminated
+}
+
+// substitution consumes $(...), $((...)), ${...} or a plain $ and returns
+// the text verbatim
+func (l *lexer) substitution() string {
+	start := l.pos
+	if l.pos+1 >= len(l.input) {
+		l.pos++
+		return "$"
+	}
+
+	switch l.input[l.pos+1] {
+	case '(':
+		l.pos += 2
+		l.skipBalanced('(', ')')
+	case '{':
+		l.pos += 2
+		l.skipBalanced('{', '}')
+	default:
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+// skipBalanced advances past the closing delimiter matching an already
+// consumed opening one, respecting nested quotes
+func (l *lexer) skipBalanced(open, close byte) {
+	depth := 1
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch c {
+		case '\\':
+			l.pos += 2
+			continue
+		case '\'':
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				l.pos = len(l.input)
+				l.err = ErrUnterminated
+				return
+			}
+			l.pos += end + 2
+			continue
+		case '"':
+			var discard strings.Builder
+			l.doubleQuoted(&discard)
+			continue
+		case '`':
+			l.backquoted()
+			continue
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				l.pos++
+				return
+			}
+		}
+		l.pos++
+	}
+	l.pos = len(l.input)
+	l.err = ErrUnterminated
+}
+
+// backquoted consumes a `...` command substitution and returns it verbatim
+func (l *lexer) backquoted() string {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.input) {
+		switch l.input[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case '`':
+			l.pos++
+			return l.input[start:l.pos]
+		}
+		l.pos++
+	}
+	l.pos = len(l.input)
+	l.err = ErrUnterminated
+	return l.input[start:]
+}
+
+// isAssignment reports whether a raw word has the form NAME=value
+func isAssignment(raw string) bool {
+	eq := strings.IndexByte(raw, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i := 0; i < eq; i++ {
+		c := raw[i]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
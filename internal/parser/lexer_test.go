@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// describe renders tokens as type:value pairs for comparison
+func describe(tokens []Token) string {
+	parts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		parts[i] = tok.Type.String() + ":" + tok.Value
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+	}{
+		{"git commit -m 'fix login'", "word:git word:commit word:-m word:fix login"},
+		{`echo "a \"b\" $HOME"`, `word:echo word:a "b" $HOME`},
+		{`cd my\ dir`, "word:cd word:my dir"},
+		{`ls a\\b`, `word:ls word:a\b`},
+		{"FOO=bar BAZ='x y' make build", "assignment:FOO=bar assignment:BAZ=x y word:make word:build"},
+		{"make CC=clang", "word:make word:CC=clang"},
+		{"A=1 cmd && B=2 cmd2", "assignment:A=1 word:cmd operator:&& assignment:B=2 word:cmd2"},
+		{"echo $(date +%s)", "word:echo word:$(date +%s)"},
+		{"echo $(dirname $(pwd))/x", "word:echo word:$(dirname $(pwd))/x"},
+		{`echo "$(git rev-parse "$(pwd)")"`, `word:echo word:$(git rev-parse "$(pwd)")`},
+		{"echo `date` ${HOME}", "word:echo word:`date` word:${HOME}"},
+		{"a | b || c |& d", "word:a operator:| word:b operator:|| word:c operator:|& word:d"},
+		{"a; b & c\nd", "word:a operator:; word:b operator:& word:c operator:; word:d"},
+		{"(cd src; make)", "operator:( word:cd word:src operator:; word:make operator:)"},
+		{"cmd > out 2>&1 < in", "word:cmd redirect:> word:out redirect:2>& word:1 redirect:< word:in"},
+		{"cmd &>> log <<< text", "word:cmd redirect:&>> word:log redirect:<<< word:text"},
+		{"make # build it", "word:make comment:# build it"},
+		{"echo a#b", "word:echo word:a#b"},
+		{"ls \\\n -la", "word:ls word:-la"},
+		{"", ""},
+	} {
+		tokens, err := Tokenize(tt.input)
+		if err != nil {
+			t.Errorf("Tokenize(%q): %v", tt.input, err)
+			continue
+		}
+		if got := describe(tokens); got != tt.want {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeRawAndPos(t *testing.T) {
+	input := `git commit -m "fix it"`
+	tokens, err := Tokenize(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := tokens[len(tokens)-1]
+	if last.Raw != `"fix it"` || last.Value != "fix it" || !last.Quoted || input[last.Pos:] != last.Raw {
+		t.Errorf("last token = %+v", last)
+	}
+	if tokens[0].Quoted {
+		t.Errorf("git reported as quoted")
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+	}{
+		{"echo 'abc", "word:echo word:abc"},
+		{`echo "abc`, "word:echo word:abc"},
+		{"echo $(date", "word:echo word:$(date"},
+		{"echo `date", "word:echo word:`date"},
+		{"echo ${HOME", "word:echo word:${HOME"},
+	} {
+		tokens, err := Tokenize(tt.input)
+		if !errors.Is(err, ErrUnterminated) {
+			t.Errorf("Tokenize(%q) error = %v, want ErrUnterminated", tt.input, err)
+		}
+		// The tokens read so far are still returned
+		if got := describe(tokens); got != tt.want {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain points HOME at a temporary directory, so that nothing the tests
+// reach can read or write the config of the user running them
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "kwik-cmd-parser")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
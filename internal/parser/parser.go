@@ -8,12 +8,61 @@ import (
 
 // ParsedCommand represents a parsed shell command
 type ParsedCommand struct {
-	Base       string
+	Base string
+	// Subcommand is the full subcommand path, e.g. "remote add" for
+	// "git remote add origin url"
 	Subcommand string
-	FullCmd    string
-	Flags      []string
-	Args       []string
-	Directory  string
+	// SubcommandPath is Subcommand split into its levels
+	SubcommandPath []string
+	FullCmd        string
+	Flags          []string
+	Args           []string
+	Directory      string
+	// FlagValues maps a flag to the value given with it (--flag=value or
+	// -f value)
+	FlagValues map[string]string
+	// Env holds NAME=value assignments that prefix the command
+	Env []string
+	// Wrappers are the commands peeled off to reach Base, e.g. sudo and
+	// timeout in "sudo timeout 5s apt update"
+	Wrappers []string
+	// Tokens is the lexed form of the command line
+	Tokens []Token
+	// Script is the command line as a list of pipelines; Base, Subcommand,
+	// Flags and Args above describe its first simple command
+	Script *List
+	// Placeholders are the arguments of the simple command that vary
+	// between runs (see template.go)
+	Placeholders []Placeholder
+	// Values are the flag values and arguments of the simple command by
+	// the slot they fill (see complete.go)
+	Values []ArgValue
+
+	// grammarPath is the tool's grammar along the subcommand path, and
+	// pendingFlag a flag ending the command that is still waiting for its
+	// value; both describe where completion continues
+	grammarPath []*Grammar
+	pendingFlag string
+}
+
+// valueFlags are flags that commonly take a separate value argument, so the
+// word after them is not mistaken for a subcommand or argument. They apply to
+// tools without a grammar (see grammars.yaml).
+var valueFlags = map[string]bool{
+	"-m": true, "--message": true,
+	"-C": true, "-c": true,
+	"-f": true, "--file": true,
+	"-o": true, "--output": true,
+	"-n": true, "--namespace": true,
+	"-u": true, "--user": true,
+	"-e": true, "--env": true,
+	"-t": true, "--tag": true,
+	"-p": true, "--port": true,
+	"-b": true, "--branch": true,
+	"-l": true, "--selector": true,
+	"-i": true, "--identity": true,
+	"--context": true, "--profile": true, "--region": true,
+	"--name": true, "--target": true, "--var": true, "--var-file": true,
 }
 
 // ParseCommand parses a shell command into its components
@@ -24,75 +73,227 @@ func ParseCommand(cmd string) *ParsedCommand {
 		return nil
 	}
 
-	// Split by whitespace
-	parts := strings.Fields(cmd)
-	if len(parts) == 0 {
+	// Lex with shell quoting rules; an unterminated quote still yields
+	// usable tokens
+	tokens, _ := Tokenize(cmd)
+
+	script := parseScript(cmd, tokens)
+	segments := script.Segments()
+	if len(segments) == 0 {
 		return nil
 	}
 
-	base := parts[0]
+	// The line as a whole is described by its first simple command
+	first := *segments[0]
+	first.FullCmd = cmd
+	first.Tokens = tokens
+	first.Script = script
+	return &first
+}
 
-	// Handle paths (./script, /path/to/command)
-	if strings.Contains(base, "/") {
-		base = strings.TrimPrefix(base, "./")
-		base = strings.TrimPrefix(base, "/")
-		if idx := strings.LastIndex(base, "/"); idx >= 0 {
-			base = base[idx+1:]
+// Segments returns the simple commands that make up the command line, e.g.
+// make, app and tee for "make build && ./bin/app serve | tee log"
+func (p *ParsedCommand) Segments() []*ParsedCommand {
+	if p.Script == nil {
+		return []*ParsedCommand{p}
+	}
+	return p.Script.Segments()
+}
+
+// parseSimpleCommand extracts base, subcommand, flags and args from the
+// tokens of a single simple command
+func parseSimpleCommand(tokens []Token) *ParsedCommand {
+	var env []string
+	var words []Token
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.Type {
+		case TokenAssignment:
+			env = append(env, t.Value)
+		case TokenRedirect:
+			// Skip the redirection target
+			if i+1 < len(tokens) && tokens[i+1].Type == TokenWord {
+				i++
+			}
+		case TokenWord:
+			words = append(words, t)
 		}
 	}
 
-	// Remove common aliases prefixes
-	base = strings.TrimPrefix(base, "sudo ")
-	base = strings.TrimPrefix(base, "doas ")
+	if len(words) == 0 {
+		if len(env) == 0 {
+			return nil
+		}
+		// A bare assignment; record it as its own command
+		return &ParsedCommand{Base: env[0], Env: env, FlagValues: map[string]string{}}
+	}
+
+	// sudo, env, timeout and friends run another command; describe that one
+	wrappers, words, wrapperEnv := unwrap(words)
+	env = append(env, wrapperEnv...)
+
+	// Handle paths (./script, /path/to/command)
+	base := commandName(words[0].Value)
 
-	var subcommand string
+	var subcommands []string
 	var flags []string
 	var args []string
+	flagValues := map[string]string{}
+	endOfFlags := false
+	pathDone := false
 
-	if len(parts) > 1 {
-		// Look for subcommand (usually not starting with -)
-		for i := 1; i < len(parts); i++ {
-			part := parts[i]
-			if strings.HasPrefix(part, "-") {
-				// It's a flag
+	// Walk the tool's grammar along the subcommand path; tools without one
+	// take their first word as the subcommand
+	tool := LookupGrammar(base)
+	node := tool
+	var path []*Grammar
+	free := 1
+	if tool != nil {
+		path = append(path, tool)
+		free = tool.depth(true)
+	}
+	takesValueFlag := func(flag string) bool {
+		if tool == nil {
+			return valueFlags[flag]
+		}
+		return takesValue(path, flag)
+	}
+
+	// Arguments that vary between runs become the placeholders of templates
+	var placeholders []Placeholder
+	addPlaceholder := func(t Token, name string, skip int) {
+		if ph, ok := placeholder(t, name, skip); ok {
+			placeholders = append(placeholders, ph)
+		}
+	}
+	// Values are learned for completing them later; flag values get the
+	// full subcommand once it is known
+	var values []ArgValue
+	addValue := func(v ArgValue) {
+		if v.Value != "" && !strings.ContainsAny(v.Value, "$`\n") {
+			values = append(values, v)
+		}
+	}
+	pendingFlag := ""
+	nameValue := func(flag string) string {
+		if name := valueName(path, flag); name != "" {
+			return name
+		}
+		return longValueNames[flag]
+	}
+
+	for i := 1; i < len(words); i++ {
+		part := words[i].Value
+		isFlag := !endOfFlags && !words[i].Quoted && strings.HasPrefix(part, "-") && part != "-"
+
+		switch {
+		case isFlag && part == "--":
+			endOfFlags = true
+		case isFlag:
+			// Handle flags with values (--flag=value, -f value)
+			if name, value, ok := strings.Cut(part, "="); ok {
+				flags = append(flags, name)
+				flagValues[name] = value
+				addValue(ArgValue{Flag: name, Value: value})
+				if eq := strings.Index(words[i].Raw, "="); eq > 0 {
+					addPlaceholder(words[i], nameValue(name), eq+1)
+				}
+			} else {
 				flags = append(flags, part)
-				// Handle flags with values (--flag=value, -f value)
-				if strings.Contains(part, "=") {
-					// Already has value attached
-				} else if i+1 < len(parts) && !strings.HasPrefix(parts[i+1], "-") {
-					// Next part is value
-					flags = append(flags, parts[i+1])
+				if takesValueFlag(part) && i+1 < len(words) {
+					flagValues[part] = words[i+1].Value
+					addValue(ArgValue{Flag: part, Value: words[i+1].Value})
+					addPlaceholder(words[i+1], nameValue(part), 0)
 					i++
+				} else if takesValueFlag(part) {
+					pendingFlag = part
 				}
-			} else if subcommand == "" {
-				// First non-flag is subcommand
-				subcommand = part
-			} else {
-				// Rest are arguments
-				args = append(args, part)
 			}
+		case !pathDone && node != nil && node.Subcommands[part] != nil && !words[i].Quoted:
+			// A listed subcommand, e.g. "add" in "git remote add"
+			node = node.Subcommands[part]
+			path = append(path, node)
+			free = node.depth(false)
+			subcommands = append(subcommands, part)
+		case !pathDone && free > 0 && looksLikeSubcommand(words[i]):
+			// An unlisted word the grammar still counts as part of the path,
+			// e.g. the resource in "kubectl get pods"
+			free--
+			node = nil
+			addValue(ArgValue{Subcommand: strings.Join(subcommands, " "), Value: part})
+			subcommands = append(subcommands, part)
+		default:
+			// Rest are arguments; after -- they are not the ones the grammar
+			// names
+			pathDone = true
+			name := ""
+			if !endOfFlags {
+				name = argName(path, len(args))
+			}
+			addPlaceholder(words[i], name, 0)
+			addValue(ArgValue{Subcommand: strings.Join(subcommands, " "), Position: len(args), Value: part})
+			args = append(args, part)
+		}
+	}
+
+	subcommand := strings.Join(subcommands, " ")
+	for i := range values {
+		if values[i].Flag != "" {
+			values[i].Subcommand = subcommand
 		}
 	}
 
 	return &ParsedCommand{
-		Base:       base,
-		Subcommand: subcommand,
-		FullCmd:    cmd,
-		Flags:      lo.Uniq(flags),
-		Args:       args,
+		Base:           base,
+		Subcommand:     subcommand,
+		SubcommandPath: subcommands,
+		Flags:          lo.Uniq(flags),
+		Args:           args,
+		FlagValues:     flagValues,
+		Env:            env,
+		Wrappers:       wrappers,
+		Placeholders:   placeholders,
+		Values:         values,
+		grammarPath:    path,
+		pendingFlag:    pendingFlag,
+	}
+}
+
+// looksLikeSubcommand reports whether a word can be a subcommand name rather
+// than an argument such as a quoted string, path, URL, IP or substitution
+func looksLikeSubcommand(t Token) bool {
+	if t.Quoted || t.Value == "" {
+		return false
+	}
+	if strings.ContainsAny(t.Value, "/$`=~{} ") || strings.HasPrefix(t.Value, ".") {
+		return false
+	}
+	// Hosts, hashes and numbers vary between runs (see classify)
+	if kind, _ := classify(t.Value); kind != "" {
+		return false
 	}
+	return true
 }
 
-// ExtractKeywords extracts searchable keywords from a command
+// ExtractKeywords extracts searchable keywords from every segment of a
+// command
 func ExtractKeywords(cmd *ParsedCommand) []string {
-	keywords := []string{cmd.Base}
+	var keywords []string
 
-	if cmd.Subcommand != "" {
-		keywords = append(keywords, cmd.Subcommand)
-	}
+	for _, seg := range cmd.Segments() {
+		keywords = append(keywords, seg.Base)
 
-	// Add common command keywords
-	keywords = append(keywords, extractCommonKeywords(cmd.Base, cmd.Subcommand)...)
+		if seg.Subcommand != "" {
+			keywords = append(keywords, seg.Subcommand)
+		}
+		if len(seg.SubcommandPath) > 1 {
+			keywords = append(keywords, seg.SubcommandPath...)
+		}
+
+		// Add common command keywords
+		keywords = append(keywords, extractCommonKeywords(seg.Base, seg.Subcommand)...)
+	}
 
 	return lo.Uniq(keywords)
 }
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholder is an argument of a command that varies between runs, such as
+// the pod in "kubectl logs pod-abc123 -n prod". In a template it is written
+// as {name}.
+type Placeholder struct {
+	// Name says what the value is: a name from the grammar (pod, namespace,
+	// branch) or the kind of token (ip, hash, path, ...)
+	Name  string
+	Value string
+	// Start and End are the byte offsets of the value in the command line
+	Start, End int
+}
+
+// longValueNames name the values of common long flags of tools whose
+// grammar does not (see grammars.yaml)
+var longValueNames = map[string]string{
+	"--namespace": "namespace",
+	"--message":   "message",
+	"--branch":    "branch",
+	"--context":   "context",
+	"--profile":   "profile",
+	"--region":    "region",
+	"--user":      "user",
+	"--port":      "port",
+	"--name":      "name",
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hashPattern   = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+	numberPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// classify returns the kind of token a value is (uuid, ip, address, url,
+// hash, number or path) and the offset of the variable part in it, which
+// skips the user of user@host. It returns "" for a value to keep, such as
+// a subcommand-like word or a substitution.
+func classify(value string) (kind string, offset int) {
+	if value == "" || strings.ContainsAny(value, "$`") {
+		return "", 0
+	}
+	if uuidPattern.MatchString(value) {
+		return "uuid", 0
+	}
+	host := value
+	if at := strings.LastIndex(value, "@"); at > 0 && !strings.Contains(value, "/") {
+		host, offset = value[at+1:], at+1
+	}
+	if net.ParseIP(host) != nil {
+		return "ip", offset
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil && net.ParseIP(h) != nil && numberPattern.MatchString(port) {
+		return "address", offset
+	}
+	switch {
+	case strings.Contains(value, "://"):
+		return "url", 0
+	case hashPattern.MatchString(value) && strings.ContainsAny(value, "abcdef") &&
+		strings.ContainsAny(value, "0123456789"):
+		return "hash", 0
+	case numberPattern.MatchString(value):
+		return "number", 0
+	case strings.Contains(value, "/") || strings.HasPrefix(value, "~"):
+		return "path", 0
+	}
+	return "", 0
+}
+
+// placeholder returns the placeholder for word t if it varies between runs.
+// name is what the grammar calls it, if anything; unnamed words are kept
+// unless they look like an IP, hash, path, number or similar. skip is the
+// number of bytes of t.Raw before the value, for --flag=value.
+func placeholder(t Token, name string, skip int) (Placeholder, bool) {
+	value := t.Value
+	if skip > 0 {
+		_, value, _ = strings.Cut(t.Value, "=")
+	}
+	if value == "" || strings.ContainsAny(value, "$`") {
+		return Placeholder{}, false
+	}
+
+	offset := 0
+	if name == "" {
+		var kind string
+		kind, offset = classify(value)
+		if kind == "" {
+			return Placeholder{}, false
+		}
+		name = kind
+		if t.Quoted {
+			// Offsets into a quoted value do not map onto the raw word
+			offset = 0
+		}
+	}
+	return Placeholder{
+		Name:  name,
+		Value: value[offset:],
+		Start: t.Pos + skip + offset,
+		End:   t.Pos + len(t.Raw),
+	}, true
+}
+
+// AllPlaceholders returns the placeholders of every segment of the command
+// line, in order
+func (p *ParsedCommand) AllPlaceholders() []Placeholder {
+	var all []Placeholder
+	for _, seg := range p.Segments() {
+		all = append(all, seg.Placeholders...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Start < all[j].Start })
+	return all
+}
+
+// Template generalizes the command line by replacing the arguments that vary
+// between runs with {name} placeholders, so near-duplicates such as
+// "kubectl logs pod-abc123 -n prod" and "kubectl logs api-x7 -n dev" share
+// the template "kubectl logs {pod} -n {namespace}". A command without such
+// arguments is its own template.
+func (p *ParsedCommand) Template() string {
+	return applyPlaceholders(p.FullCmd, 0, p.AllPlaceholders())
+}
+
+// SegmentTemplate is Template for a single simple command of the line
+func (p *ParsedCommand) SegmentTemplate() string {
+	if len(p.Tokens) == 0 {
+		return p.FullCmd
+	}
+	return applyPlaceholders(p.FullCmd, p.Tokens[0].Pos, p.Placeholders)
+}
+
+// applyPlaceholders replaces the placeholders in text, which starts at
+// offset start of the command line
+func applyPlaceholders(text string, start int, placeholders []Placeholder) string {
+	var b strings.Builder
+	last := 0
+	for _, ph := range placeholders {
+		from, to := ph.Start-start, ph.End-start
+		if from < last || to > len(text) {
+			continue
+		}
+		b.WriteString(text[last:from])
+		b.WriteString("{" + ph.Name + "}")
+		last = to
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	for cmd, want := range map[string]string{
+		"kubectl logs pod-abc123 -n prod":                     "kubectl logs {pod} -n {namespace}",
+		"kubectl get pods api-7d9f -n=prod -o wide":           "kubectl get pods {name} -n={namespace} -o wide",
+		"ssh deploy@10.0.0.12":                                "ssh deploy@{ip}",
+		"curl http://10.0.0.12:8080/health":                   "curl {url}",
+		"git checkout -b feature/login":                       "git checkout -b {branch}",
+		"git push origin main":                                "git push {remote} {branch}",
+		`git commit -m "fix login"`:                           "git commit -m {message}",
+		"git show 3f2a9c1":                                    "git show {hash}",
+		"docker stop 0f1e2d3c4b5a && docker rm 0f1e2d3c4b5a":  "docker stop {container} && docker rm {container}",
+		"cat /etc/hosts | grep 10.0.0.1":                      "cat {path} | grep {ip}",
+		"sudo systemctl restart nginx":                        "sudo systemctl restart {unit}",
+		"kill -9 4242":                                        "kill -9 {number}",
+		"aws s3 cp ./dist s3://bucket/app --region eu-west-1": "aws s3 cp {path} {url} --region {region}",
+		"echo 1c9a8f2e-93f5-4d3b-8b55-2f0f7a1d6c3e":           "echo {uuid}",
+		// Nothing varies
+		"make build":           "make build",
+		"go test ./... -run X": "go test {path} -run X",
+		"echo $HOME":           "echo $HOME",
+		"git checkout -- main": "git checkout -- main",
+	} {
+		if got := ParseCommand(cmd).Template(); got != want {
+			t.Errorf("Template(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	cmd := ParseCommand("ssh deploy@10.0.0.12 && kubectl logs web-1 -n prod")
+	var got []Placeholder
+	for _, ph := range cmd.AllPlaceholders() {
+		got = append(got, Placeholder{Name: ph.Name, Value: ph.Value})
+		if cmd.FullCmd[ph.Start:ph.End] != ph.Value {
+			t.Errorf("%s spans %q, want %q", ph.Name, cmd.FullCmd[ph.Start:ph.End], ph.Value)
+		}
+	}
+	want := []Placeholder{{Name: "ip", Value: "10.0.0.12"}, {Name: "pod", Value: "web-1"}, {Name: "namespace", Value: "prod"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("placeholders = %+v, want %+v", got, want)
+	}
+
+	segments := cmd.Segments()
+	if got := segments[1].SegmentTemplate(); got != "kubectl logs {pod} -n {namespace}" {
+		t.Errorf("segment template = %q", got)
+	}
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// wrapperGrammar describes the options of a command that runs another
+// command, so the wrapped command can be found after them
+type wrapperGrammar struct {
+	// valueFlags take a separate value argument (sudo -u root)
+	valueFlags []string
+	// positional is the number of arguments before the command
+	// (timeout 5s cmd)
+	positional int
+	// assignments allows NAME=value words before the command (env A=B cmd)
+	assignments bool
+	// numericFlags accepts a bare -N priority (nice -10 cmd)
+	numericFlags bool
+	// shellString means a single quoted argument is itself a command line
+	// (watch 'kubectl get pods')
+	shellString bool
+}
+
+// wrapperGrammars holds the option grammars of known wrappers. Wrappers that
+// are configured but not listed here are assumed to take flags only.
+var wrapperGrammars = map[string]wrapperGrammar{
+	"sudo": {
+		valueFlags:  []string{"-u", "--user", "-g", "--group", "-C", "--close-from", "-D", "--chdir", "-h", "--host", "-p", "--prompt", "-r", "--role", "-t", "--type", "-U", "--other-user", "-T", "--command-timeout"},
+		assignments: true,
+	},
+	"doas": {
+		valueFlags: []string{"-u", "-C"},
+	},
+	"env": {
+		valueFlags:  []string{"-u", "--unset", "-C", "--chdir", "-S", "--split-string"},
+		assignments: true,
+	},
+	"time": {
+		valueFlags: []string{"-f", "--format", "-o", "--output"},
+	},
+	"nice": {
+		valueFlags:   []string{"-n", "--adjustment"},
+		numericFlags: true,
+	},
+	"ionice": {
+		valueFlags: []string{"-c", "--class", "-n", "--classdata"},
+	},
+	"nohup": {},
+	"stdbuf": {
+		valueFlags: []string{"-i", "--input", "-o", "--output", "-e", "--error"},
+	},
+	"xargs": {
+		valueFlags: []string{"-I", "-a", "--arg-file", "-d", "--delimiter", "-E", "-L", "--max-lines", "-n", "--max-args", "-P", "--max-procs", "-s", "--max-chars"},
+	},
+	"watch": {
+		valueFlags:  []string{"-n", "--interval", "-q", "--equexit"},
+		shellString: true,
+	},
+	"timeout": {
+		valueFlags: []string{"-s", "--signal", "-k", "--kill-after"},
+		positional: 1,
+	},
+}
+
+// DefaultWrappers returns the wrapper commands peeled off when none are
+// configured
+func DefaultWrappers() []string {
+	return []string{
+		"sudo", "doas", "env", "time", "nice", "ionice", "nohup",
+		"stdbuf", "xargs", "watch", "timeout",
+	}
+}
+
+// wrappers holds the wrapper commands in use; the parser reads no config of
+// its own, so callers pass the configured list to SetWrappers
+var wrappers = DefaultWrappers()
+
+// SetWrappers replaces the wrapper commands peeled off by ParseCommand,
+// normally with the wrappers key of the config
+func SetWrappers(names []string) {
+	wrappers = names
+}
+
+// isWrapper reports whether name is a configured wrapper command
+func isWrapper(name string) bool {
+	return lo.Contains(wrappers, name)
+}
+
+// unwrap peels configured wrapper commands off the front of a simple
+// command. It returns the wrappers in the order written, the words of the
+// wrapped command and any NAME=value assignments given to the wrappers. A
+// wrapper with nothing after it (sudo -i) is returned as the command itself.
+func unwrap(words []Token) (wrappers []string, rest []Token, env []string) {
+	rest = words
+	for len(rest) > 0 {
+		name := commandName(rest[0].Value)
+		if !isWrapper(name) {
+			break
+		}
+		grammar := wrapperGrammars[name]
+
+		i := skipWrapperOptions(rest, grammar)
+		var assignments []string
+		for grammar.assignments && i < len(rest) && !rest[i].Quoted && isAssignment(rest[i].Raw) {
+			assignments = append(assignments, rest[i].Value)
+			i++
+		}
+		if i >= len(rest) {
+			break
+		}
+
+		inner := rest[i:]
+		// watch 'git status' runs its argument through the shell
+		if grammar.shellString && len(inner) == 1 && inner[0].Quoted {
+			tokens, _ := Tokenize(inner[0].Value)
+			var words []Token
+			for _, t := range tokens {
+				if t.Type == TokenWord {
+					words = append(words, t)
+				}
+			}
+			if len(words) == 0 {
+				break
+			}
+			inner = words
+		}
+
+		wrappers = append(wrappers, name)
+		env = append(env, assignments...)
+		rest = inner
+	}
+	return wrappers, rest, env
+}
+
+// skipWrapperOptions returns the index of the first word after a wrapper's
+// options and positional arguments
+func skipWrapperOptions(words []Token, grammar wrapperGrammar) int {
+	i := 1
+	for i < len(words) {
+		w := words[i].Value
+		if words[i].Quoted || !strings.HasPrefix(w, "-") || w == "-" {
+			break
+		}
+		i++
+		if w == "--" {
+			break
+		}
+		if grammar.numericFlags && isDigits(strings.TrimPrefix(w, "-")) {
+			continue
+		}
+		if !strings.Contains(w, "=") && lo.Contains(grammar.valueFlags, w) {
+			i++
+		}
+	}
+
+	for n := 0; n < grammar.positional && i < len(words); n++ {
+		i++
+	}
+	return i
+}
+
+// commandName strips the directory from a command word (/usr/bin/sudo)
+func commandName(word string) string {
+	if idx := strings.LastIndex(word, "/"); idx >= 0 {
+		return word[idx+1:]
+	}
+	return word
+}
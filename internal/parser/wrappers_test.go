@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnwrap(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		wrappers string
+		command  string
+		env      string
+	}{
+		{"sudo -u postgres psql -c 'select 1'", "sudo", "psql -c select 1", ""},
+		{"sudo --user=root systemctl restart nginx", "sudo", "systemctl restart nginx", ""},
+		{"sudo -E HOME=/root make install", "sudo", "make install", "HOME=/root"},
+		{"/usr/bin/sudo ls", "sudo", "ls", ""},
+		{"env -i A=B PATH=/bin cmd arg", "env", "cmd arg", "A=B PATH=/bin"},
+		{"env -u LANG -- locale", "env", "locale", ""},
+		{"timeout 5s curl example.com", "timeout", "curl example.com", ""},
+		{"timeout -s KILL -k 1m 30s make test", "timeout", "make test", ""},
+		{"nice -n 10 make -j8", "nice", "make -j8", ""},
+		{"nice -10 tar czf a.tgz dir", "nice", "tar czf a.tgz dir", ""},
+		{"watch -n 2 'kubectl get pods'", "watch", "kubectl get pods", ""},
+		{"xargs -I {} -P 4 rm {}", "xargs", "rm {}", ""},
+		// Stacked wrappers are peeled in the order written
+		{"sudo -u app nice -n 5 timeout 10m ./backup.sh", "sudo nice timeout", "./backup.sh", ""},
+		{"time env DEBUG=1 nohup go run .", "time env nohup", "go run .", "DEBUG=1"},
+		{"sudo env A=1 sudo -u b B=2 cmd", "sudo env sudo", "cmd", "A=1 B=2"},
+		// A wrapper with nothing to run is the command itself
+		{"sudo -i", "", "sudo -i", ""},
+		{"timeout 5s", "", "timeout 5s", ""},
+		{"env", "", "env", ""},
+		// Not wrappers
+		{"git commit -m 'sudo ls'", "", "git commit -m sudo ls", ""},
+	} {
+		tokens, err := Tokenize(tt.input)
+		if err != nil {
+			t.Fatalf("Tokenize(%q): %v", tt.input, err)
+		}
+		wrappers, rest, env := unwrap(tokens)
+		words := make([]string, len(rest))
+		for i, tok := range rest {
+			words[i] = tok.Value
+		}
+		got := []string{strings.Join(wrappers, " "), strings.Join(words, " "), strings.Join(env, " ")}
+		if want := []string{tt.wrappers, tt.command, tt.env}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unwrap(%q) = %q, want %q", tt.input, got, want)
+		}
+	}
+
+	// The wrapped command is what is parsed
+	cmd := ParseCommand("sudo -u deploy env RAILS_ENV=production bundle exec rake db:migrate")
+	if cmd.Base != "bundle" || cmd.Subcommand != "exec" || !reflect.DeepEqual(cmd.Wrappers, []string{"sudo", "env"}) ||
+		!reflect.DeepEqual(cmd.Env, []string{"RAILS_ENV=production"}) {
+		t.Errorf("ParseCommand = base %q sub %q wrappers %v env %v", cmd.Base, cmd.Subcommand, cmd.Wrappers, cmd.Env)
+	}
+}
+
+func TestSetWrappers(t *testing.T) {
+	t.Cleanup(func() { SetWrappers(DefaultWrappers()) })
+
+	// A configured wrapper without a grammar is taken to have flags only;
+	// one left out of the list is the command itself
+	SetWrappers([]string{"proxychains", "env"})
+	cmd := ParseCommand("proxychains -q env A=1 curl example.com")
+	if cmd.Base != "curl" || !reflect.DeepEqual(cmd.Wrappers, []string{"proxychains", "env"}) {
+		t.Errorf("ParseCommand = base %q wrappers %v, want curl under proxychains env", cmd.Base, cmd.Wrappers)
+	}
+	if cmd := ParseCommand("sudo ls"); cmd.Base != "sudo" || len(cmd.Wrappers) != 0 {
+		t.Errorf("ParseCommand(sudo ls) = base %q wrappers %v, want sudo unwrapped", cmd.Base, cmd.Wrappers)
+	}
+}
@@ -0,0 +1,196 @@
+// Package project identifies the project a directory belongs to, so history
+// can be shared between the subdirectories and clones of the same project.
+package project
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Info describes the project a directory belongs to: the enclosing git
+// repository and the kinds of project found there
+type Info struct {
+	// Root is the top-level directory of the working tree
+	Root string
+	// Branch is the checked out branch, or the abbreviated commit for a
+	// detached HEAD
+	Branch string
+	// Remote is the normalized URL of the origin remote (or of the first
+	// remote if there is no origin), e.g. github.com/user/repo
+	Remote string
+	// Types are the project types detected from marker files (see Types)
+	Types []string
+}
+
+// ID returns the identity of the project: its remote, so clones at
+// different paths are the same project, or the root of a repository
+// without remotes. It is "" outside a repository.
+func (i Info) ID() string {
+	if i.Remote != "" {
+		return i.Remote
+	}
+	return i.Root
+}
+
+// Detect finds the git repository enclosing dir by walking up to the first
+// directory containing .git, and the types of project dir belongs to. It
+// reads the repository files directly rather than running git, so it is
+// cheap enough for every keystroke. Outside a repository only Types is set.
+func Detect(dir string) Info {
+	info := detectRepository(dir)
+	info.Types = Types(dir, info.Root)
+	return info
+}
+
+// detectRepository returns the git repository enclosing dir, if any
+func detectRepository(dir string) Info {
+	if dir == "" {
+		return Info{}
+	}
+	dir = filepath.Clean(dir)
+	for {
+		if gitDir, ok := findGitDir(dir); ok {
+			return Info{
+				Root:   dir,
+				Branch: readBranch(gitDir),
+				Remote: readRemote(commonDir(gitDir)),
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Info{}
+		}
+		dir = parent
+	}
+}
+
+// findGitDir returns the git directory of a working tree rooted at dir. A
+// .git file (worktrees, submodules) points to it with "gitdir: <path>".
+func findGitDir(dir string) (string, bool) {
+	path := filepath.Join(dir, ".git")
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if fi.IsDir() {
+		return path, true
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", false
+	}
+	return resolve(dir, strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))), true
+}
+
+// commonDir returns the directory holding the config shared by all
+// worktrees of a repository
+func commonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	return resolve(gitDir, strings.TrimSpace(string(data)))
+}
+
+// resolve interprets path relative to base unless it is absolute
+func resolve(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// readBranch returns the branch HEAD points to
+func readBranch(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref:"); ok {
+		return strings.TrimPrefix(strings.TrimSpace(ref), "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
+
+// readRemote returns the normalized URL of the origin remote, or of the
+// first remote in the config
+func readRemote(gitDir string) string {
+	f, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var section, first string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !strings.HasPrefix(section, "[remote ") || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		url := NormalizeRemote(strings.Trim(strings.TrimSpace(value), `"`))
+		if section == `[remote "origin"]` {
+			return url
+		}
+		if first == "" {
+			first = url
+		}
+	}
+	return first
+}
+
+// NormalizeRemote reduces a remote URL to host/path so the SSH and HTTPS
+// URLs of a repository compare equal. Credentials, ports, the scheme and a
+// trailing .git are dropped:
+//
+//	git@github.com:user/repo.git         -> github.com/user/repo
+//	https://token@github.com/user/repo/  -> github.com/user/repo
+//	ssh://git@host:2222/team/repo.git    -> host/team/repo
+func NormalizeRemote(url string) string {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return ""
+	}
+
+	if scheme, rest, ok := strings.Cut(url, "://"); ok {
+		if scheme == "file" {
+			url = rest
+		} else {
+			host, path, _ := strings.Cut(rest, "/")
+			url = hostName(host) + "/" + path
+		}
+	} else if host, path, ok := strings.Cut(url, ":"); ok && !strings.ContainsAny(host, "/") {
+		// scp-like syntax: [user@]host:path
+		url = hostName(host) + "/" + strings.TrimPrefix(path, "/")
+	}
+
+	url = strings.TrimRight(url, "/")
+	return strings.TrimSuffix(url, ".git")
+}
+
+// hostName strips credentials and the port from a URL host and lowercases it
+func hostName(host string) string {
+	if idx := strings.LastIndex(host, "@"); idx >= 0 {
+		host = host[idx+1:]
+	}
+	if idx := strings.LastIndex(host, ":"); idx >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:idx]
+	}
+	return strings.ToLower(host)
+}
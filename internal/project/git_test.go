@@ -0,0 +1,101 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeRemote(t *testing.T) {
+	for url, want := range map[string]string{
+		"git@github.com:user/repo.git":            "github.com/user/repo",
+		"https://github.com/user/repo":            "github.com/user/repo",
+		"https://token@GitHub.com/user/repo.git/": "github.com/user/repo",
+		"ssh://git@host:2222/team/repo.git":       "host/team/repo",
+		"file:///srv/git/repo.git":                "/srv/git/repo",
+		"/srv/git/repo.git":                       "/srv/git/repo",
+		"":                                        "",
+	} {
+		if got := NormalizeRemote(url); got != want {
+			t.Errorf("NormalizeRemote(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	writeFile(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/feature/x\n")
+	writeFile(t, filepath.Join(repo, ".git", "config"), `[core]
+	bare = false
+[remote "upstream"]
+	url = git@github.com:other/repo.git
+[remote "origin"]
+	url = https://github.com/me/repo.git
+`)
+	// A worktree: .git is a file pointing into the main repository
+	wt := filepath.Join(root, "wt")
+	writeFile(t, filepath.Join(repo, ".git", "worktrees", "wt", "HEAD"), "0123456789abcdef\n")
+	writeFile(t, filepath.Join(repo, ".git", "worktrees", "wt", "commondir"), "../..\n")
+	writeFile(t, filepath.Join(wt, ".git"), "gitdir: "+filepath.Join(repo, ".git", "worktrees", "wt")+"\n")
+
+	sub := filepath.Join(repo, "src", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Detect(sub)
+	want := Info{Root: repo, Branch: "feature/x", Remote: "github.com/me/repo", Types: []string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect(sub) = %+v, want %+v", got, want)
+	}
+
+	got = Detect(wt)
+	want = Info{Root: wt, Branch: "0123456", Remote: "github.com/me/repo", Types: []string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect(worktree) = %+v, want %+v", got, want)
+	}
+
+	if got := Detect(root); got.ID() != "" {
+		t.Errorf("Detect outside a repository = %+v, want none", got)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	writeFile(t, filepath.Join(repo, "go.mod"), "module example.com/repo\n")
+	writeFile(t, filepath.Join(repo, "Makefile"), "all:\n")
+	writeFile(t, filepath.Join(repo, "deploy", "terraform", "main.tf"), "")
+	writeFile(t, filepath.Join(repo, "web", "package.json"), "{}")
+	writeFile(t, filepath.Join(root, "Cargo.toml"), "")
+
+	for _, tc := range []struct {
+		dir, root string
+		want      []string
+	}{
+		{repo, repo, []string{"go", "make"}},
+		// Markers up to the repository root count, the root's parent does not
+		{filepath.Join(repo, "web"), repo, []string{"go", "make", "node"}},
+		{filepath.Join(repo, "deploy"), repo, []string{"go", "make", "terraform"}},
+		// Outside a repository only the directory itself is looked at
+		{filepath.Join(repo, "web"), "", []string{"node"}},
+		{root, "", []string{"rust"}},
+	} {
+		if got := Types(tc.dir, tc.root); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Types(%s, %q) = %v, want %v", tc.dir, tc.root, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// marker is a file whose presence identifies a type of project
+type marker struct {
+	projectType string
+	// patterns are glob patterns relative to the project directory
+	patterns []string
+}
+
+// markers lists the project types that are detected and their files
+var markers = []marker{
+	{"go", []string{"go.mod"}},
+	{"node", []string{"package.json"}},
+	{"rust", []string{"Cargo.toml"}},
+	{"make", []string{"Makefile", "makefile", "GNUmakefile"}},
+	{"terraform", []string{"*.tf", "terraform/*.tf"}},
+	{"docker-compose", []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}},
+}
+
+// Types returns the sorted project types whose marker files are found in
+// dir or, inside a repository, in any directory between dir and its root.
+// root must be dir or one of its parents. A Go package deep in a repository
+// is still a Go project.
+func Types(dir, root string) []string {
+	if dir == "" {
+		return nil
+	}
+	dir = filepath.Clean(dir)
+
+	found := map[string]bool{}
+	for {
+		for _, m := range markers {
+			if !found[m.projectType] && hasMarker(dir, m.patterns) {
+				found[m.projectType] = true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if root == "" || dir == root || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	types := make([]string, 0, len(found))
+	for t := range found {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// hasMarker reports whether any of the patterns matches a file in dir
+func hasMarker(dir string, patterns []string) bool {
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if fi, err := os.Stat(match); err == nil && !fi.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package suggester
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/db"
+)
+
+// explainedCommand is a suggestion in the JSON output of Explain
+type explainedCommand struct {
+	Rank    int    `json:"rank"`
+	Command string `json:"command"`
+	// Template and Variants are set for a suggestion standing for several
+	// commands sharing a template
+	Template string `json:"template,omitempty"`
+	Variants int    `json:"variants,omitempty"`
+	*db.Explanation
+}
+
+// Explain prints up to limit suggestions for partial like Suggest, with the
+// score of each broken down into its signals, the weights applied and the
+// inputs they were computed from. asJSON prints the same as JSON.
+func Explain(partial string, limit int, includeFailed, asJSON bool) error {
+	if err := db.Init(); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	rq := suggestQuery(partial, limit, includeFailed)
+	rq.Explain = true
+	partial = rq.Partial
+	ranked, err := db.GetRankedCommands(rq)
+	if err != nil {
+		return fmt.Errorf("failed to get ranked commands: %w", err)
+	}
+
+	if asJSON {
+		out := struct {
+			Partial      string             `json:"partial"`
+			Directory    string             `json:"directory"`
+			Project      string             `json:"project,omitempty"`
+			ProjectTypes []string           `json:"project_types,omitempty"`
+			Suggestions  []explainedCommand `json:"suggestions"`
+		}{partial, rq.Directory, rq.Project, rq.ProjectTypes, []explainedCommand{}}
+		for i, rc := range ranked {
+			e := explainedCommand{Rank: i + 1, Command: rc.FullCommand, Explanation: rc.Explanation}
+			if rc.Variants > 1 {
+				e.Template, e.Variants = rc.Template, rc.Variants
+			}
+			out.Suggestions = append(out.Suggestions, e)
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(ranked) == 0 {
+		yellow.Println("No commands found. Start tracking commands with 'kwik-cmd track <command>'")
+		return nil
+	}
+
+	cyan.Print("=== Ranking for '")
+	white.Print(partial)
+	cyan.Println("' ===")
+	dim.Printf("Directory: %s", rq.Directory)
+	if rq.Project != "" {
+		dim.Printf(", project: %s", rq.Project)
+	}
+	if len(rq.ProjectTypes) > 0 {
+		dim.Printf(" (%s)", strings.Join(rq.ProjectTypes, ", "))
+	}
+	fmt.Println()
+
+	now := time.Now()
+	for i, rc := range ranked {
+		e := rc.Explanation
+		fmt.Println()
+		bold.Printf("%d. ", i+1)
+		printCommand(rc.FullCommand, rc.Positions)
+		dim.Printf(" (score: %.3f)\n", e.Score)
+		if rc.Variants > 1 {
+			dim.Printf("   template: %s (%d variants)\n", rc.Template, rc.Variants)
+		}
+		dim.Printf("   %-13s %6s %7s %8s\n", "signal", "value", "weight", "score")
+		for _, s := range e.Signals {
+			line := fmt.Sprintf("   %-13s %6.3f %7.2f %+8.3f", s.Name, s.Value, s.Weight, s.Score)
+			if s.Score == 0 {
+				dim.Print(line)
+			} else {
+				fmt.Print(line)
+			}
+			if input := signalInput(s.Name, e, now); input != "" {
+				dim.Print("  " + input)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// signalInput describes the inputs a signal was computed from
+func signalInput(name string, e *db.Explanation, now time.Time) string {
+	switch name {
+	case "recency":
+		if e.LastUsed.IsZero() {
+			return "never used"
+		}
+		return fmt.Sprintf("last used %s (%s ago, half-life %gd)",
+			e.LastUsed.Local().Format("2006-01-02 15:04"), ago(now.Sub(e.LastUsed)), e.HalfLifeDays)
+	case "frequency":
+		return fmt.Sprintf("%d runs, frecency %.2f of %.2f", e.RunCount, e.Frecency, e.MaxFrecency)
+	case "directory":
+		switch e.DirectoryMatch {
+		case "directory":
+			return e.Directory + " (current directory)"
+		case "project":
+			return e.Directory + " (same project)"
+		case "related":
+			return e.Directory + " (parent or child)"
+		}
+		return e.Directory
+	case "project_type":
+		return strings.Join(e.ProjectTypes, ", ")
+	case "prediction":
+		if e.Probability > 0 {
+			return fmt.Sprintf("follows the last command %.0f%% of the time", e.Probability*100)
+		}
+	case "fuzzy":
+		if e.Match == "fuzzy" {
+			return fmt.Sprintf("match quality %.2f", e.MatchQuality)
+		}
+	case "failure":
+		return fmt.Sprintf("%d of %d runs succeeded, %.0f%% of recent runs failed",
+			e.Successes, e.RunCount, e.FailureRate*100)
+	}
+	return ""
+}
+
+// ago formats a duration coarsely: 45s, 12m, 3h, 5d
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
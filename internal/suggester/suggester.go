@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/project"
+	"github.com/samber/lo"
 )
 
 var (
@@ -17,22 +20,46 @@ var (
 	magenta = color.New(color.FgMagenta)
 	white   = color.New(color.FgWhite)
 	dim     = color.New(color.FgBlack)
+	// matched highlights the characters matched by what was typed
+	matched = color.New(color.FgYellow, color.Bold)
 )
 
-// Suggest provides command suggestions using ranking engine
-func Suggest(partial string) error {
+// printCommand prints a command in green with the runes at positions
+// highlighted
+func printCommand(command string, positions []int) {
+	hit := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		hit[p] = true
+	}
+
+	runes := []rune(command)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && hit[end] == hit[start] {
+			end++
+		}
+		if hit[start] {
+			matched.Print(string(runes[start:end]))
+		} else {
+			green.Print(string(runes[start:end]))
+		}
+		start = end
+	}
+}
+
+// Suggest provides command suggestions using ranking engine, at most limit
+// of them. Commands that have never succeeded are left out unless
+// includeFailed is set.
+func Suggest(partial string, limit int, includeFailed bool) error {
 	if err := db.Init(); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
-	// Get current directory for context awareness
-	currentDir, _ := os.Getwd()
-
-	partial = strings.TrimSpace(partial)
-
 	// Use ranking engine for intelligent suggestions
-	ranked, err := db.GetRankedCommands(partial, currentDir, 10)
+	rq := suggestQuery(partial, limit, includeFailed)
+	partial, currentDir, currentProject := rq.Partial, rq.Directory, rq.Project
+	ranked, err := db.GetRankedCommands(rq)
 	if err != nil {
 		return fmt.Errorf("failed to get ranked commands: %w", err)
 	}
@@ -45,14 +72,14 @@ func Suggest(partial string) error {
 	cyan.Print("=== Suggestions for '")
 	white.Print(partial)
 	cyan.Println("' ===")
-	dim.Print("(Ranked by: recency + frequency + directory context)")
+	dim.Println("(Ranked by: recency + frecency + directory/project context)")
 
 	for i, rc := range ranked {
 		// Number in bold cyan
 		bold.Printf("  %d. ", i+1)
 		
-		// Command in green
-		green.Print(rc.FullCommand)
+		// Command in green, matched characters highlighted
+		printCommand(rc.FullCommand, rc.Positions)
 		
 		// Score in dim
 		dim.Printf(" (score: %.2f, used: %d times)", rc.Score, rc.Frequency)
@@ -60,25 +87,66 @@ func Suggest(partial string) error {
 		// Current dir tag in magenta
 		if currentDir != "" && rc.Directory == currentDir {
 			magenta.Print(" [current dir]")
+		} else if currentProject != "" && rc.Project == currentProject {
+			magenta.Print(" [same project]")
+		}
+
+		if rc.NeverSucceeded() {
+			yellow.Print(" [never succeeded]")
+		} else if rc.FailureRate >= 0.5 {
+			yellow.Printf(" [fails %.0f%%]", rc.FailureRate*100)
 		}
 		fmt.Println()
+
+		// Near-duplicates are shown once, as their template
+		if rc.Variants > 1 {
+			dim.Printf("     template: %s (%d variants)\n", rc.Template, rc.Variants)
+		}
 	}
 
 	return nil
 }
 
+// suggestQuery builds the query Suggest ranks partial with, in the current
+// directory and shell session. Explain ranks the same query so that it
+// explains exactly the suggestions Suggest shows.
+func suggestQuery(partial string, limit int, includeFailed bool) db.RankQuery {
+	currentDir, _ := os.Getwd()
+	here := project.Detect(currentDir)
+	return db.RankQuery{
+		Partial:       strings.TrimSpace(partial),
+		Directory:     currentDir,
+		Project:       here.ID(),
+		ProjectTypes:  here.Types,
+		SessionID:     os.Getenv("KWIK_CMD_SESSION"),
+		Limit:         limit,
+		IncludeFailed: includeFailed,
+		Templates:     true,
+	}
+}
+
 // SuggestPlain returns command suggestions as plain text (no colors/headers)
 // Used by zsh shell integration for inline suggestions
-func SuggestPlain(partial string, limit int) ([]string, error) {
+func SuggestPlain(rq db.RankQuery) ([]string, error) {
 	if err := db.Init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
-	currentDir, _ := os.Getwd()
-	partial = strings.TrimSpace(partial)
+	return RankedPlain(rq)
+}
 
-	ranked, err := db.GetRankedCommands(partial, currentDir, limit)
+// RankedPlain returns ranked suggestions from the already opened database.
+// Shared by SuggestPlain and the daemon. The project and its types are
+// detected from the directory, and commands sharing a template are
+// collapsed as in the other suggestions.
+func RankedPlain(rq db.RankQuery) ([]string, error) {
+	rq.Partial = strings.TrimSpace(rq.Partial)
+	here := project.Detect(rq.Directory)
+	rq.Project, rq.ProjectTypes = here.ID(), here.Types
+	rq.Templates = true
+
+	ranked, err := db.GetRankedCommands(rq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ranked commands: %w", err)
 	}
@@ -93,8 +161,11 @@ func SuggestPlain(partial string, limit int) ([]string, error) {
 
 // SuggestPlainSplit returns command suggestions split by recent and frequent
 // Returns a map with "recent" and "frequent" keys
-// Used by zsh shell integration for categorized suggestions
-func SuggestPlainSplit(partial string, limit int) (map[string][]string, error) {
+// Used by zsh shell integration for categorized suggestions. Commands
+// sharing a template are listed once, filled with the values of the most
+// recently used of them. Commands that have never succeeded are left out
+// unless includeFailed is set.
+func SuggestPlainSplit(partial string, limit int, includeFailed bool) (map[string][]string, error) {
 	if err := db.Init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
@@ -102,48 +173,66 @@ func SuggestPlainSplit(partial string, limit int) (map[string][]string, error) {
 
 	partial = strings.TrimSpace(partial)
 
-	// Get recent commands
-	recentCmds, err := db.GetRecentCommands(limit * 2)
+	// Get recent commands; extra ones make up for collapsed near-duplicates
+	recentCmds, err := db.GetRecentByPrefix(partial, limit*templateSlack, includeFailed)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recent commands: %w", err)
 	}
 
 	// Get frequent commands
-	frequentCmds, err := db.GetTopCommands(limit * 2)
+	frequentCmds, err := db.GetFrecentByPrefix(partial, limit*templateSlack, includeFailed)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get frequent commands: %w", err)
 	}
 
-	// Filter by partial match if provided
-	var recentFiltered []string
-	var frequentFiltered []string
-
-	for _, c := range recentCmds {
-		if partial == "" || strings.HasPrefix(strings.ToLower(c.Base), strings.ToLower(partial)) ||
-			strings.HasPrefix(strings.ToLower(c.FullCommand), strings.ToLower(partial)) {
-			recentFiltered = append(recentFiltered, c.FullCommand)
-			if len(recentFiltered) >= limit {
-				break
-			}
+	var templates []string
+	for _, c := range append(recentCmds, frequentCmds...) {
+		if c.HasPlaceholders() {
+			templates = append(templates, c.Template)
 		}
 	}
-
-	for _, c := range frequentCmds {
-		if partial == "" || strings.HasPrefix(strings.ToLower(c.Base), strings.ToLower(partial)) ||
-			strings.HasPrefix(strings.ToLower(c.FullCommand), strings.ToLower(partial)) {
-			frequentFiltered = append(frequentFiltered, c.FullCommand)
-			if len(frequentFiltered) >= limit {
-				break
-			}
-		}
+	variants, err := db.LatestVariants(lo.Uniq(templates))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get templates: %w", err)
 	}
 
 	return map[string][]string{
-		"recent":   recentFiltered,
-		"frequent": frequentFiltered,
+		"recent":   collapseVariants(recentCmds, variants, partial, limit),
+		"frequent": collapseVariants(frequentCmds, variants, partial, limit),
 	}, nil
 }
 
+// templateSlack is how many more commands are fetched for a list than it
+// shows, as near-duplicates are collapsed into one
+const templateSlack = 3
+
+// collapseVariants returns up to limit commands, the first of each template
+// replaced by the template's latest variant if that still starts with the
+// partial, and the others dropped
+func collapseVariants(commands []db.Command, variants map[string]db.TemplateUsage, partial string, limit int) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, limit)
+	for _, c := range commands {
+		if len(result) == limit {
+			break
+		}
+		command := c.FullCommand
+		if c.HasPlaceholders() {
+			if seen[c.Template] {
+				continue
+			}
+			seen[c.Template] = true
+			if v, ok := variants[c.Template]; ok && strings.HasPrefix(strings.ToLower(v.Latest), strings.ToLower(partial)) {
+				command = v.Latest
+			}
+		}
+		if !lo.Contains(result, command) {
+			result = append(result, command)
+		}
+	}
+	return result
+}
+
 // Search searches commands by keywords
 func Search(keywords string) error {
 	if err := db.Init(); err != nil {
@@ -158,77 +247,32 @@ func Search(keywords string) error {
 
 	// Get current directory for context
 	currentDir, _ := os.Getwd()
+	currentProject := project.Detect(currentDir).ID()
 
-	// Split keywords
-	words := strings.Fields(strings.ToLower(keywords))
-
-	// Get all recent commands and filter
-	all, err := db.GetRecentCommands(100)
+	// An invalid query is reported before the header
+	matches, err := findMatches(keywords, 20)
 	if err != nil {
-		return fmt.Errorf("failed to get commands: %w", err)
+		return err
 	}
 
 	cyan.Print("=== Search results for '")
 	white.Print(keywords)
-	cyan.Print("' ===")
-
-	var matches []db.RankedCommand
-	for _, c := range all {
-		cmdLower := strings.ToLower(c.FullCommand)
-		match := true
-		for _, w := range words {
-			if !strings.Contains(cmdLower, w) {
-				match = false
-				break
-			}
-		}
-		if match {
-			// Calculate simple score for search results
-			score := float64(c.Frequency) * 0.5
-			matches = append(matches, db.RankedCommand{
-				Command: c,
-				Score:   score,
-			})
-		}
-	}
-
-	if len(matches) == 0 {
-		// Try keyword search from DB
-		keywordResults, err := db.SearchByKeyword(keywords)
-		if err != nil {
-			return fmt.Errorf("search failed: %w", err)
-		}
-		for _, c := range keywordResults {
-			matches = append(matches, db.RankedCommand{
-				Command: c,
-				Score:   float64(c.Frequency),
-			})
-		}
-	}
+	cyan.Println("' ===")
 
 	if len(matches) == 0 {
 		yellow.Println("No matching commands found.")
 		return nil
 	}
 
-	// Sort by score
-	for i := 0; i < len(matches)-1; i++ {
-		for j := i + 1; j < len(matches); j++ {
-			if matches[j].Score > matches[i].Score {
-				matches[i], matches[j] = matches[j], matches[i]
-			}
-		}
-	}
-
 	for i, rc := range matches {
-		if i >= 20 {
-			break
-		}
 		bold.Printf("%d. ", i+1)
-		green.Println(rc.FullCommand)
+		printCommand(rc.FullCommand, rc.Positions)
+		fmt.Println()
 		dim.Printf("   Used %d times, last: %s", rc.Frequency, rc.LastUsed.Format("2006-01-02 15:04"))
 		if currentDir != "" && rc.Directory == currentDir {
 			magenta.Print(" [current dir]")
+		} else if currentProject != "" && rc.Project == currentProject {
+			magenta.Print(" [same project]")
 		}
 		fmt.Println()
 	}
@@ -244,69 +288,85 @@ func SearchPlain(keywords string, limit int) ([]string, error) {
 	}
 	defer db.Close()
 
+	return MatchesPlain(keywords, limit)
+}
+
+// MatchesPlain returns search results from the already opened database.
+// Shared by SearchPlain and the daemon.
+func MatchesPlain(keywords string, limit int) ([]string, error) {
 	keywords = strings.TrimSpace(keywords)
 	if keywords == "" {
 		return nil, fmt.Errorf("please provide search keywords")
 	}
 
-	words := strings.Fields(strings.ToLower(keywords))
-	all, err := db.GetRecentCommands(100)
+	matches, err := findMatches(keywords, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commands: %w", err)
+		return nil, err
 	}
 
-	var matches []db.RankedCommand
-	for _, c := range all {
-		cmdLower := strings.ToLower(c.FullCommand)
-		match := true
-		for _, w := range words {
-			if !strings.Contains(cmdLower, w) {
-				match = false
-				break
-			}
-		}
-		if match {
-			score := float64(c.Frequency) * 0.5
-			matches = append(matches, db.RankedCommand{
-				Command: c,
-				Score:   score,
-			})
-		}
+	commands := make([]string, 0, len(matches))
+	for _, rc := range matches {
+		commands = append(commands, rc.FullCommand)
 	}
 
-	if len(matches) == 0 {
-		keywordResults, err := db.SearchByKeyword(keywords)
+	return commands, nil
+}
+
+// findMatches returns the commands matching a search query (see
+// db.ParseTextQuery), best match first. A query of plain words that no
+// command contains is matched fuzzily instead, so "kgp" finds
+// "kubectl get pods".
+func findMatches(keywords string, limit int) ([]db.RankedCommand, error) {
+	q, err := db.ParseTextQuery(keywords, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := db.SearchText(q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	if len(matches) == 0 && q.Plain {
+		matches, err = db.SearchCommands(q.Terms, limit)
 		if err != nil {
 			return nil, fmt.Errorf("search failed: %w", err)
 		}
-		for _, c := range keywordResults {
-			matches = append(matches, db.RankedCommand{
-				Command: c,
-				Score:   float64(c.Frequency),
-			})
-		}
+		return matches, nil
 	}
 
-	if len(matches) == 0 {
-		return []string{}, nil
+	for i := range matches {
+		matches[i].Positions = termPositions(matches[i].FullCommand, q.Terms)
 	}
+	return matches, nil
+}
 
-	// Sort by score
-	for i := 0; i < len(matches)-1; i++ {
-		for j := i + 1; j < len(matches); j++ {
-			if matches[j].Score > matches[i].Score {
-				matches[i], matches[j] = matches[j], matches[i]
+// termPositions returns the indexes of the runes of command that are part
+// of an occurrence of a word of terms, ignoring case
+func termPositions(command string, terms []string) []int {
+	text := []rune(strings.ToLower(command))
+	if len(text) != len([]rune(command)) {
+		return nil
+	}
+
+	hit := make([]bool, len(text))
+	for _, term := range terms {
+		for _, word := range strings.Fields(strings.ToLower(term)) {
+			w := []rune(word)
+			for i := 0; i+len(w) <= len(text); i++ {
+				if string(text[i:i+len(w)]) == word {
+					for j := range w {
+						hit[i+j] = true
+					}
+				}
 			}
 		}
 	}
 
-	commands := make([]string, 0, len(matches))
-	for i, rc := range matches {
-		if i >= limit {
-			break
+	var positions []int
+	for i, h := range hit {
+		if h {
+			positions = append(positions, i)
 		}
-		commands = append(commands, rc.FullCommand)
 	}
-
-	return commands, nil
+	return positions
 }
@@ -0,0 +1,185 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
+)
+
+// ErrPaused is returned for a command run in a shell session that is paused
+// or incognito
+var ErrPaused = errors.New("tracking is paused")
+
+// The pause state of each shell session lives in files of the data
+// directory so the shell hooks can read it for the prompt indicator without
+// running kwik-cmd:
+//
+//	~/.kwik-cmd/paused/<session>     the Unix time the pause ends, 0 for none
+//	~/.kwik-cmd/incognito/<session>  empty
+const (
+	pauseDirName     = "paused"
+	incognitoDirName = "incognito"
+)
+
+// PauseState describes whether the runs of a shell session are tracked
+type PauseState struct {
+	// Paused is set while the session is paused; Until is when the pause
+	// ends, zero if it lasts until resume
+	Paused bool
+	Until  time.Time
+	// Incognito is set if the session is incognito
+	Incognito bool
+}
+
+// Active reports whether runs are not being tracked
+func (s PauseState) Active() bool {
+	return s.Paused || s.Incognito
+}
+
+func (s PauseState) String() string {
+	switch {
+	case s.Incognito:
+		return "incognito until the shell exits or kwik-cmd resume"
+	case s.Paused && s.Until.IsZero():
+		return "paused until kwik-cmd resume"
+	case s.Paused:
+		left := max(time.Until(s.Until).Round(time.Minute), time.Minute)
+		return fmt.Sprintf("paused until %s (%s left)", s.Until.Format("15:04"),
+			strings.TrimSuffix(left.String(), "0s"))
+	}
+	return "tracking"
+}
+
+// errNoSession is returned when pausing without a shell session
+var errNoSession = errors.New("no shell session (KWIK_CMD_SESSION is not set; load the shell hook first)")
+
+// CurrentPause returns the pause state of session. An expired pause is
+// removed.
+func CurrentPause(session string) (PauseState, error) {
+	var s PauseState
+	if session == "" {
+		return s, nil
+	}
+	incognito, paused, err := stateFiles(session)
+	if err != nil {
+		return s, err
+	}
+
+	if _, err := os.Stat(incognito); err == nil {
+		s.Incognito = true
+	}
+
+	data, err := os.ReadFile(paused)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	s.Paused = true
+	until, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || until <= 0 {
+		// A damaged file still pauses; resume removes it
+		return s, nil
+	}
+	s.Until = time.Unix(until, 0)
+	if !s.Until.After(time.Now()) {
+		os.Remove(paused)
+		return PauseState{Incognito: s.Incognito}, nil
+	}
+	return s, nil
+}
+
+// Pause stops tracking in a shell session for d, or until Resume if d is 0
+func Pause(session string, d time.Duration) (PauseState, error) {
+	if session == "" {
+		return PauseState{}, errNoSession
+	}
+	_, paused, err := stateFiles(session)
+	if err != nil {
+		return PauseState{}, err
+	}
+
+	s := PauseState{Paused: true}
+	var until int64
+	if d > 0 {
+		s.Until = time.Now().Add(d)
+		until = s.Until.Unix()
+	}
+	if err := writeState(paused, []byte(strconv.FormatInt(until, 10)+"\n")); err != nil {
+		return PauseState{}, err
+	}
+	return s, nil
+}
+
+// Incognito stops tracking in a shell session until it exits or Resume
+func Incognito(session string) error {
+	if session == "" {
+		return errNoSession
+	}
+	incognito, _, err := stateFiles(session)
+	if err != nil {
+		return err
+	}
+	return writeState(incognito, nil)
+}
+
+// Resume ends the pause and the incognito mode of a shell session. It
+// returns the state that was ended.
+func Resume(session string) (PauseState, error) {
+	s, err := CurrentPause(session)
+	if err != nil || session == "" {
+		return s, err
+	}
+	incognito, paused, err := stateFiles(session)
+	if err != nil {
+		return s, err
+	}
+	for _, path := range []string{incognito, paused} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// stateFiles returns the incognito and pause files of a session
+func stateFiles(session string) (incognito, paused string, err error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", "", err
+	}
+	name := sessionFileName(session)
+	return filepath.Join(dir, incognitoDirName, name), filepath.Join(dir, pauseDirName, name), nil
+}
+
+// writeState writes a state file, creating its directory
+func writeState(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// sessionFileName makes a session id safe to use as a file name. The ids
+// the hooks create (host-pid-time) are kept as they are, so the hooks can
+// find the file.
+func sessionFileName(session string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, session)
+	if strings.Trim(name, ".") == "" {
+		return "_" + name
+	}
+	return name
+}
@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPauseExpires(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	s, err := Pause("s1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Paused || s.Until.IsZero() {
+		t.Fatalf("Pause = %+v, want a timed pause", s)
+	}
+	if s, _ := CurrentPause("s1"); !s.Paused {
+		t.Error("session is not paused")
+	}
+
+	// A pause that has ended is removed
+	_, paused, err := stateFiles("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if err := os.WriteFile(paused, []byte(ended+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := CurrentPause("s1"); s.Active() {
+		t.Errorf("expired pause still active: %+v", s)
+	}
+	if _, err := os.Stat(paused); !os.IsNotExist(err) {
+		t.Error("expired pause file was not removed")
+	}
+
+	// A damaged file keeps the session paused until resume
+	if err := os.WriteFile(paused, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := CurrentPause("s1"); !s.Paused || !s.Until.IsZero() {
+		t.Errorf("damaged pause file = %+v, want paused until resume", s)
+	}
+}
+
+func TestPauseIsPerSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := Pause("s1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Incognito("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := CurrentPause("s2"); s.Active() {
+		t.Errorf("pausing s1 paused s2: %+v", s)
+	}
+	if s, _ := CurrentPause(""); s.Active() {
+		t.Errorf("pausing s1 paused runs without a session: %+v", s)
+	}
+	if _, err := Pause("", 0); err == nil {
+		t.Error("expected an error pausing without a session")
+	}
+
+	s, err := Resume("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Paused || !s.Incognito {
+		t.Errorf("Resume returned %+v, want the paused incognito state", s)
+	}
+	if s, _ := CurrentPause("s1"); s.Active() {
+		t.Errorf("s1 still %s after resume", s)
+	}
+}
+
+func TestSessionFileName(t *testing.T) {
+	for _, tt := range []struct{ session, want string }{
+		{"laptop.local-4242-1700000000", "laptop.local-4242-1700000000"},
+		{"a/b", "a_b"},
+		{"../x", ".._x"},
+		{"..", "_.."},
+		{".", "_."},
+		{"héllo world", "h_llo_world"},
+	} {
+		if got := sessionFileName(tt.session); got != tt.want {
+			t.Errorf("sessionFileName(%q) = %q, want %q", tt.session, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package tracker
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/kaustuvbot/kwik-cmd/internal/config"
 	"github.com/kaustuvbot/kwik-cmd/internal/db"
+	"github.com/kaustuvbot/kwik-cmd/internal/ignore"
 	"github.com/kaustuvbot/kwik-cmd/internal/parser"
+	"github.com/kaustuvbot/kwik-cmd/internal/project"
+	"github.com/kaustuvbot/kwik-cmd/internal/redact"
 )
 
 var (
@@ -18,67 +25,268 @@ var (
 	dim    = color.New(color.FgBlack)
 )
 
+// ErrIgnored is returned for a command run an ignore rule keeps from being
+// tracked
+var ErrIgnored = errors.New("command is ignored")
+
 // TrackCommand tracks a command execution (legacy, assumes success)
 func TrackCommand(cmd string) error {
 	return TrackCommandWithStatus(cmd, true, 0)
 }
 
+// Execution carries the context of a single command run as reported by the
+// shell hooks
+type Execution struct {
+	Success   bool
+	ExitCode  int
+	Duration  time.Duration
+	SessionID string
+	TTY       string
+	Directory string
+	Hostname  string
+	// ExecutedAt is when the run happened, now if zero; imports replay
+	// older runs
+	ExecutedAt time.Time
+}
+
+// Resolve fills in the context the caller did not supply from the current
+// process: working directory, KWIK_CMD_SESSION, terminal and hostname
+func (e Execution) Resolve() Execution {
+	if e.Directory == "" {
+		e.Directory = GetCurrentDirectory()
+	}
+	if e.SessionID == "" {
+		e.SessionID = os.Getenv("KWIK_CMD_SESSION")
+	}
+	if e.TTY == "" {
+		e.TTY = currentTTY()
+	}
+	if e.Hostname == "" {
+		e.Hostname, _ = os.Hostname()
+	}
+	return e
+}
+
 // TrackCommandWithStatus tracks a command with its exit status
 func TrackCommandWithStatus(cmd string, success bool, exitCode int) error {
+	return TrackExecution(cmd, Execution{Success: success, ExitCode: exitCode})
+}
+
+// TrackExecution tracks a command run together with its execution context.
+// It returns ErrPaused or ErrIgnored if the run is not tracked (see Record).
+func TrackExecution(cmd string, e Execution) error {
 	if err := db.Init(); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
-	parsed := parser.ParseCommand(cmd)
+	parsed, err := Record(cmd, e.Resolve())
+	if err != nil {
+		return err
+	}
+
+	PrintTracked(parsed.FullCmd, e.Success, e.ExitCode)
+	return nil
+}
+
+// Record stores a command run in the already opened database. It is shared
+// by the CLI and the daemon, which keeps one connection open. Runs are not
+// stored while tracking is paused or an ignore rule matches them; Record
+// returns ErrPaused or ErrIgnored for them.
+func Record(cmd string, e Execution) (*parser.ParsedCommand, error) {
+	pause, err := CurrentPause(e.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if pause.Active() {
+		return nil, fmt.Errorf("%w: %s", ErrPaused, pause)
+	}
+
+	match, ignored, err := Ignored(cmd, e.Directory)
+	if err != nil {
+		return nil, err
+	}
+	if ignored {
+		return nil, fmt.Errorf("%w by %s", ErrIgnored, match)
+	}
+
+	// Secrets never reach the database
+	redactor, err := newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	if redactor != nil {
+		cmd, _ = redactor.Redact(cmd)
+	}
+
+	parsed, run := parseRun(cmd)
 	if parsed == nil {
-		return fmt.Errorf("failed to parse command")
+		return nil, fmt.Errorf("failed to parse command")
 	}
 
-	// Get current directory
-	dir, err := os.Getwd()
+	repo := project.Detect(e.Directory)
+	run.Directory = e.Directory
+	run.Project = repo.ID()
+	run.ProjectTypes = repo.Types
+	run.Execution = db.Execution{
+		ExitCode:   e.ExitCode,
+		Success:    e.Success,
+		DurationMs: e.Duration.Milliseconds(),
+		SessionID:  e.SessionID,
+		Hostname:   e.Hostname,
+		TTY:        e.TTY,
+		GitRoot:    repo.Root,
+		GitBranch:  repo.Branch,
+		GitRemote:  repo.Remote,
+		ExecutedAt: e.ExecutedAt,
+	}
+
+	// Store the command, its keywords and flags and the execution event in one
+	// transaction so concurrent hooks never see a half-written run
+	if _, err := db.RecordRun(run); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// Ignored returns the ignore rule of config that keeps cmd run in directory
+// from being tracked, if any. cmd is the line as typed, so that a leading
+// space can be told apart.
+func Ignored(cmd, directory string) (ignore.Match, bool, error) {
+	cfg := config.Get()
+	rules, err := ignore.New(ignore.Settings{
+		Commands:    cfg.IgnoreCommands,
+		Patterns:    cfg.IgnorePatterns,
+		Directories: cfg.IgnoreDirectories,
+		Space:       cfg.IgnoreSpace,
+		MinLength:   cfg.IgnoreMinLength,
+		MaxLength:   cfg.IgnoreMaxLength,
+	})
 	if err != nil {
-		dir = ""
+		return ignore.Match{}, false, err
 	}
+	match, ignored := rules.Match(cmd, directory)
+	return match, ignored, nil
+}
 
-	// Add to database
-	commandID, err := db.AddCommand(parsed.Base, parsed.Subcommand, parsed.FullCmd, dir)
+// newRedactor returns the redactor configured by redact and
+// redact_patterns, or nil if redaction is off
+func newRedactor() (*redact.Redactor, error) {
+	cfg := config.Get()
+	if !cfg.Redact {
+		return nil, nil
+	}
+	return redact.New(cfg.RedactPatterns)
+}
+
+// Scrub redacts the secrets already stored in the history, as Record
+// does for new runs (see db.Scrub)
+func Scrub(dryRun bool) (db.ScrubResult, error) {
+	redactor, err := newRedactor()
 	if err != nil {
-		return fmt.Errorf("failed to add command: %w", err)
+		return db.ScrubResult{}, err
 	}
+	if redactor == nil {
+		return db.ScrubResult{}, fmt.Errorf("redaction is turned off (kwik-cmd config set redact true)")
+	}
+	return db.Scrub(redactor.Redact, func(fullCommand string) (db.Run, bool) {
+		parsed, run := parseRun(fullCommand)
+		return run, parsed != nil
+	}, dryRun)
+}
 
-	// Add keywords
-	keywords := parser.ExtractKeywords(parsed)
-	for _, kw := range keywords {
-		if err := db.AddKeyword(commandID, kw); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to add keyword %s: %v\n", kw, err)
-		}
+// Reindex re-parses every stored command so history recorded by older
+// versions picks up parser improvements, and re-detects the project of every
+// directory that still exists. It returns the number of commands re-parsed
+// and the number whose project changed.
+func Reindex() (parsed, projects int, err error) {
+	parsed, err = db.Reindex(func(fullCommand string) (db.Run, bool) {
+		parsed, run := parseRun(fullCommand)
+		return run, parsed != nil
+	})
+	if err != nil {
+		return 0, 0, err
 	}
 
-	// Add flags with meanings
-	for _, flag := range parsed.Flags {
-		meaning := parser.FlagMeaning(flag)
-		if err := db.AddFlag(commandID, flag, meaning); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to add flag %s: %v\n", flag, err)
+	projects, err = db.ReindexProjects(func(dir string) (string, []string, bool) {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return "", nil, false
 		}
+		info := project.Detect(dir)
+		return info.ID(), info.Types, true
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to detect projects: %w", err)
+	}
+	return parsed, projects, nil
+}
+
+// parseRun parses a command line into what the database stores about it
+func parseRun(cmd string) (*parser.ParsedCommand, db.Run) {
+	parsed := parser.ParseCommand(cmd)
+	if parsed == nil {
+		return nil, db.Run{}
 	}
 
-	// Record usage with success/failure status
-	if err := db.RecordUsage(commandID, success, exitCode); err != nil {
-		return fmt.Errorf("failed to record usage: %w", err)
+	// Every segment of a pipeline or list counts for stats and flags; the
+	// full line is kept as the command suggestions return
+	var segments []db.Segment
+	var flags []db.Flag
+	var args []string
+	var values []db.ArgValue
+	seen := map[string]bool{}
+	parsed.Script.Walk(func(seg *parser.ParsedCommand, op string) {
+		segments = append(segments, db.Segment{
+			Base:        seg.Base,
+			Subcommand:  seg.Subcommand,
+			FullSegment: seg.FullCmd,
+			Op:          op,
+			Wrapper:     strings.Join(seg.Wrappers, " "),
+			Template:    seg.SegmentTemplate(),
+		})
+		for _, flag := range seg.Flags {
+			if !seen[flag] {
+				seen[flag] = true
+				flags = append(flags, db.Flag{Flag: flag, Meaning: parser.FlagMeaning(flag)})
+			}
+		}
+		args = append(args, seg.Args...)
+		for _, v := range seg.Values {
+			values = append(values, db.ArgValue{
+				Base:       seg.Base,
+				Subcommand: v.Subcommand,
+				Flag:       v.Flag,
+				Position:   v.Position,
+				Value:      v.Value,
+			})
+		}
+	})
+
+	return parsed, db.Run{
+		Base:        parsed.Base,
+		Subcommand:  parsed.Subcommand,
+		FullCommand: parsed.FullCmd,
+		Keywords:    parser.ExtractKeywords(parsed),
+		Flags:       flags,
+		Segments:    segments,
+		Args:        args,
+		ArgValues:   values,
+		Template:    parsed.Template(),
 	}
+}
 
-	// Colored output
+// PrintTracked prints the confirmation line for a tracked command
+func PrintTracked(fullCmd string, success bool, exitCode int) {
 	green.Print("✓ Tracked: ")
-	white.Print(parsed.FullCmd)
-	
+	white.Print(fullCmd)
+
 	if success && exitCode == 0 {
 		cyan.Print(" [success]")
 	} else {
 		yellow.Printf(" [failed, exit=%d]", exitCode)
 	}
 	fmt.Println()
-	return nil
 }
 
 // ShowStats displays command usage statistics
@@ -130,9 +338,34 @@ func ShowStats() error {
 		}
 	}
 
+	// Show templates, counting near-duplicates that differ only in their
+	// arguments together
+	templates, err := db.GetTemplateUsage(5)
+	if err != nil {
+		return fmt.Errorf("failed to get templates: %w", err)
+	}
+
+	if len(templates) > 0 {
+		bold.Print("\n=== Most Used Templates ===\n")
+		for i, t := range templates {
+			bold.Printf("%d. ", i+1)
+			green.Print(t.Template)
+			dim.Printf(" (used %d times, %d variants, last: %s)\n", t.RunCount, t.Variants, t.Latest)
+		}
+	}
+
 	return nil
 }
 
+// currentTTY returns the terminal attached to stdin, if any
+func currentTTY() string {
+	tty, err := os.Readlink("/proc/self/fd/0")
+	if err != nil || !strings.HasPrefix(tty, "/dev/") || tty == "/dev/null" {
+		return ""
+	}
+	return tty
+}
+
 // GetCurrentDirectory returns the current working directory
 func GetCurrentDirectory() string {
 	dir, err := os.Getwd()
@@ -2,33 +2,126 @@
 # kwik-cmd Bash Integration - Auto-tracking
 # Add to ~/.bashrc: source /path/to/kwik-cmd/shell/bash_hook.sh
 
-# Commands to ignore
-KWIk_IGNORE="cd ls lla ll la pwd echo exit export declare typeset unset shift
-local readonly help which what time fg bg jobs kill builtin test [ 
-true false logout shopt umask setx setenv printenv eval exec
-source alias unalias"
-
 # Check if kwik-cmd exists
 command -v kwik-cmd >/dev/null 2>&1 || return
 
-# Auto-track commands
-kwik_preexec() {
-    local cmd="$BASH_COMMAND"
-    
+# Every shell gets its own session id so executions can be grouped
+export KWIK_CMD_SESSION="${HOSTNAME}-$$-$(date +%s)"
+
+# Current time in microseconds (bash 5 has EPOCHREALTIME)
+_kwik_now_us() {
+    if [ -n "$EPOCHREALTIME" ]; then
+        echo "${EPOCHREALTIME/[.,]/}"
+    else
+        echo $(( $(date +%s) * 1000000 ))
+    fi
+}
+
+# Data directory holding the pause state of each session
+_KWIK_DIR="$(kwik-cmd config dir 2>/dev/null)"
+
+# $KWIK_CMD_PROMPT is "[incognito] " or "[paused] " while this shell's
+# commands are not tracked; show it with PS1='${KWIK_CMD_PROMPT}...'
+KWIK_CMD_PROMPT=""
+_kwik_prompt_state() {
+    local ends
+    KWIK_CMD_PROMPT=""
+    [ -n "$_KWIK_DIR" ] || return
+    if [ -e "$_KWIK_DIR/incognito/$KWIK_CMD_SESSION" ]; then
+        KWIK_CMD_PROMPT="[incognito] "
+    elif [ -r "$_KWIK_DIR/paused/$KWIK_CMD_SESSION" ]; then
+        # Holds the time the pause ends, 0 until kwik-cmd resume; like
+        # kwik-cmd, a damaged file still counts as paused
+        ends=$(<"$_KWIK_DIR/paused/$KWIK_CMD_SESSION")
+        if ! [[ "$ends" =~ ^[0-9]+$ ]] || [ "$ends" -eq 0 ] ||
+            [ "$ends" -gt "${EPOCHSECONDS:-$(date +%s)}" ]; then
+            KWIK_CMD_PROMPT="[paused] "
+        fi
+    fi
+}
+
+# Forget the pause state of this shell when it exits
+_kwik_exit() {
+    [ -n "$_KWIK_DIR" ] || return
+    local file
+    for file in "$_KWIK_DIR/incognito/$KWIK_CMD_SESSION" "$_KWIK_DIR/paused/$KWIK_CMD_SESSION"; do
+        [ -e "$file" ] && rm -f -- "$file"
+    done
+}
+
+# Record the start time of the first command run after the prompt
+_kwik_preexec() {
+    [ -n "$_KWIK_AT_PROMPT" ] || return
+    unset _KWIK_AT_PROMPT
+    _KWIK_CMD_START=$(_kwik_now_us)
+}
+
+# Run _kwik_preexec ahead of a DEBUG trap of your own (starship, direnv)
+# rather than replacing it. Bash hides the trap from sourced files and
+# functions, so this runs once from the first prompt and is handed the
+# output of trap -p DEBUG, which quotes the trap for eval.
+_KWIK_CHAIN_DEBUG='_kwik_chain_debug "$(trap -p DEBUG)"'
+_kwik_chain_debug() {
+    PROMPT_COMMAND="${PROMPT_COMMAND/;$_KWIK_CHAIN_DEBUG/}"
+    eval "set -- $1"
+    trap "_kwik_preexec${3:+; $3}" DEBUG
+}
+
+# Track the finished command with its exit code and duration
+kwik_precmd() {
+    local exit_code=$?
+    local start="$_KWIK_CMD_START"
+    unset _KWIK_CMD_START
+    _KWIK_AT_PROMPT=1
+    _kwik_prompt_state
+
+    # Only track when a new history entry was added
+    local entry
+    entry=$(HISTTIMEFORMAT= history 1)
+    local histnum="${entry%%[!0-9 ]*}"
+    [ "$histnum" = "$_KWIK_LAST_HISTNUM" ] && return
+    _KWIK_LAST_HISTNUM="$histnum"
+
+    # history prints the number, a space or * (edited) and a space; strip
+    # only those so that a leading space of the command itself is kept for
+    # the ignore_space rule
+    local cmd="${entry#"${entry%%[0-9]*}"}"
+    cmd="${cmd#"${cmd%%[!0-9]*}"}"
+    cmd="${cmd:2}"
+
     # Skip empty
     [ -z "$cmd" ] && return
-    
-    # Skip kwik-cmd itself
+
+    # Skip kwik-cmd itself; the ignore rules of config are applied by
+    # kwik-cmd track (see kwik-cmd ignore)
     [[ "$cmd" == kwik-cmd* ]] && return
-    
-    # Skip ignored commands
-    local base="${cmd%% *}"
-    for ignore in $KWIk_IGNORE; do
-        [ "$base" = "$ignore" ] && return
-    done
-    
+
+    local duration_ms=0
+    [ -n "$start" ] && duration_ms=$(( ($(_kwik_now_us) - start) / 1000 ))
+
+    # Suggest a correction right away if the command looks mistyped; only
+    # the statuses kwik-cmd fix can correct are worth the wait
+    case "$exit_code" in
+        1|2|64|127) kwik-cmd fix --hint --exit-code "$exit_code" -- "$cmd" ;;
+    esac
+
     # Track in background
-    kwik-cmd track "$cmd" 2>/dev/null &
+    (kwik-cmd track "$cmd" --exit-code "$exit_code" --duration "${duration_ms}ms" \
+        --session "$KWIK_CMD_SESSION" --tty "$(tty 2>/dev/null)" >/dev/null 2>&1 &)
 }
 
-PROMPT_COMMAND="kwik_preexec"
+# Leave an EXIT trap of your own alone
+[ -z "$(trap -p EXIT)" ] && trap '_kwik_exit' EXIT
+_kwik_prompt_state
+
+_KWIK_AT_PROMPT=1
+_KWIK_LAST_HISTNUM=$(HISTTIMEFORMAT= history 1)
+_KWIK_LAST_HISTNUM="${_KWIK_LAST_HISTNUM%%[!0-9 ]*}"
+if [ -n "${bash_preexec_imported:-${__bp_imported:-}}" ]; then
+    # bash-preexec owns the DEBUG trap and PROMPT_COMMAND; join its hooks
+    # (it hands each precmd function the command's exit status)
+    preexec_functions+=(_kwik_preexec)
+    precmd_functions+=(kwik_precmd)
+else
+    PROMPT_COMMAND="kwik_precmd;$_KWIK_CHAIN_DEBUG${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
@@ -1,7 +1,8 @@
 #!/bin/zsh
 # kwik-cmd - Zsh auto-suggestions integration
 # Mode 1: Inline ghost text (automatic as you type)
-# Mode 2: Tab expands to full list picker (fzf or numbered menu)
+# Mode 2: Tab expands to full list picker (fzf or numbered menu); Tab then
+#         moves through the placeholders of the picked command
 # Global: Ctrl+R for keyword search
 
 # ============================================================
@@ -59,12 +60,88 @@ zle -N autosuggest-execute _zsh_autosuggest_accept
 # Mode 2: Tab expands to full list picker (improved)
 # ============================================================
 
+# Placeholders of the picked command still to visit, as name/value pairs
+typeset -ga _kwik_fields
+
+# Put a picked command in the buffer. Its placeholders (the pod, the IP,
+# ...), filled with the most recently used values, are visited with Tab.
+_kwik_pick() {
+    BUFFER="$1"
+    CURSOR=${#BUFFER}
+    _kwik_fields=()
+    local name value
+    while IFS=$'\t' read -r name value; do
+        [[ -n "$value" ]] && _kwik_fields+=("$name" "$value")
+    done < <(kwik-cmd template --plain "$1" 2>/dev/null)
+    if (( ${#_kwik_fields} )); then
+        CURSOR=0
+        _kwik_next_field
+    fi
+}
+
+# Select the value of the next placeholder after the cursor
+_kwik_next_field() {
+    local name="${_kwik_fields[1]}" value="${_kwik_fields[2]}"
+    _kwik_fields=("${(@)_kwik_fields[3,-1]}")
+
+    local rest="${BUFFER:$CURSOR}"
+    local before="${rest%%"$value"*}"
+    if [[ "$before" == "$rest" ]]; then
+        # Edited away; stop visiting placeholders
+        _kwik_fields=()
+        return 1
+    fi
+    MARK=$((CURSOR + ${#before}))
+    CURSOR=$((MARK + ${#value}))
+    REGION_ACTIVE=1
+    zle -M "{$name}: edit it, or Tab for the next placeholder"
+}
+
+_kwik_clear_fields() {
+    _kwik_fields=()
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook precmd _kwik_clear_fields
+
+# Complete the flag value or argument at the cursor from the values used
+# there before, e.g. the namespace after "kubectl -n "
+_kwik_complete_arg() {
+    local -a values
+    values=("${(@f)$(kwik-cmd complete-arg --line "$LBUFFER" 2>/dev/null)}")
+    values=(${values:#})
+    (( ${#values} )) || return 1
+
+    local value="${values[1]}"
+    if (( ${#values} > 1 )); then
+        if ! command -v fzf &>/dev/null; then
+            zle -M "${(j:  :)values}"
+            return 0
+        fi
+        value=$(printf '%s\n' "${values[@]}" | \
+            FZF_DEFAULT_OPTS="--height=40% --reverse --prompt='value> '" fzf 2>/dev/null)
+        zle reset-prompt
+        [[ -z "$value" ]] && return 0
+    fi
+
+    # Replace what has been typed of the value
+    local word="${LBUFFER##*[[:space:]=]}"
+    LBUFFER="${LBUFFER%"$word"}${(q-)value} "
+}
+
 _kwik_tab_expand() {
     local prefix="$BUFFER"
 
+    # Visit the placeholders of a picked command first
+    if (( ${#_kwik_fields} )); then
+        _kwik_next_field && return
+    fi
+
     # Don't expand for empty buffer
     [[ -z "$prefix" ]] && zle expand-or-complete && return
 
+    # Complete a flag value or argument from history before whole commands
+    _kwik_complete_arg && return
+
     # Get suggestions with split mode (recent + frequent)
     local suggestions
     suggestions=$(kwik-cmd suggest --split --limit 10 "$prefix" 2>/dev/null)
@@ -106,8 +183,7 @@ _kwik_tab_expand() {
         local first_cmd
         first_cmd="${recent[1]:-${frequent[1]}}"
         if [[ "$first_cmd" == "$prefix"* ]]; then
-            BUFFER="$first_cmd"
-            CURSOR=${#BUFFER}
+            _kwik_pick "$first_cmd"
             zle reset-prompt
             return
         fi
@@ -129,8 +205,7 @@ _kwik_tab_expand() {
         cmd=$(echo "$selected" | tail -1)
 
         if [[ -n "$cmd" ]]; then
-            BUFFER="$cmd"
-            CURSOR=${#BUFFER}
+            _kwik_pick "$cmd"
             zle reset-prompt
         fi
     else
@@ -193,8 +268,7 @@ _kwik_interactive_menu() {
             echo ""
             ;;
         $'\r'|$'\n')  # Enter - accept first
-            BUFFER="$recent[1]"
-            CURSOR=${#BUFFER}
+            _kwik_pick "$recent[1]"
             ;;
         [0-9])
             local num="$char"
@@ -209,11 +283,10 @@ _kwik_interactive_menu() {
 
             if [[ $num -le $(($num_recent + num_frequent)) && $num -ge 1 ]]; then
                 if [[ $num -le $num_recent ]]; then
-                    BUFFER="$recent[$num]"
+                    _kwik_pick "$recent[$num]"
                 else
-                    BUFFER="${@[$num]}"
+                    _kwik_pick "${@[$num]}"
                 fi
-                CURSOR=${#BUFFER}
             fi
             ;;
         $'\x1b')  # Arrow keys
@@ -321,6 +394,6 @@ bindkey "^R" kwik-keyword-search
 
 echo "kwik-cmd suggestions loaded!"
 echo "  Type command prefix → inline ghost suggestion appears"
-echo "  Press Tab → full list picker (recent + frequent)"
+echo "  Press Tab → full list picker (recent + frequent), then Tab through placeholders"
 echo "  Press Right Arrow → accept suggestion"
 echo "  Ctrl+R → keyword search"
@@ -2,30 +2,85 @@
 # kwik-cmd Zsh Integration - Auto-tracking
 # Add to ~/.zshrc: source /path/to/kwik-cmd/shell/zsh_hook.sh
 
-# Commands to ignore
-KWIk_IGNORE="cd ls lla ll la pwd echo exit export declare typeset unset shift local readonly help which what time fg bg jobs kill builtin test [ true false logout shopt umask setx setenv printenv eval exec"
-
 # Check if kwik-cmd is available
 [ -z $commands[(i)kwik-cmd] ] && return
 
-# Auto-track commands
-preexec() {
-    local cmd="$1"
-    
+zmodload zsh/datetime 2>/dev/null
+autoload -Uz add-zsh-hook
+
+# Every shell gets its own session id so executions can be grouped
+typeset -gx KWIK_CMD_SESSION="${HOST}-$$-${EPOCHSECONDS}"
+
+# Data directory holding the pause state of each session
+typeset -g _KWIK_DIR="$(kwik-cmd config dir 2>/dev/null)"
+
+# $KWIK_CMD_PROMPT is "[incognito] " or "[paused] " while this shell's
+# commands are not tracked; show it with setopt PROMPT_SUBST and
+# PROMPT='${KWIK_CMD_PROMPT}...'
+typeset -g KWIK_CMD_PROMPT=""
+_kwik_prompt_state() {
+    local ends
+    KWIK_CMD_PROMPT=""
+    [[ -n "$_KWIK_DIR" ]] || return
+    if [[ -e "$_KWIK_DIR/incognito/$KWIK_CMD_SESSION" ]]; then
+        KWIK_CMD_PROMPT="[incognito] "
+    elif [[ -r "$_KWIK_DIR/paused/$KWIK_CMD_SESSION" ]]; then
+        # Holds the time the pause ends, 0 until kwik-cmd resume; like
+        # kwik-cmd, a damaged file still counts as paused
+        ends=$(<"$_KWIK_DIR/paused/$KWIK_CMD_SESSION")
+        if [[ "$ends" != <-> ]] || (( ends == 0 || ends > EPOCHSECONDS )); then
+            KWIK_CMD_PROMPT="[paused] "
+        fi
+    fi
+}
+
+# Forget the pause state of this shell when it exits
+_kwik_exit() {
+    [[ -n "$_KWIK_DIR" ]] || return
+    local file
+    for file in "$_KWIK_DIR/incognito/$KWIK_CMD_SESSION" "$_KWIK_DIR/paused/$KWIK_CMD_SESSION"; do
+        [[ -e "$file" ]] && rm -f -- "$file"
+    done
+}
+
+# Remember the command and its start time
+_kwik_preexec() {
+    _KWIK_CMD="$1"
+    _KWIK_CMD_START=$EPOCHREALTIME
+}
+
+# Track the finished command with its exit code and duration
+_kwik_precmd() {
+    local exit_code=$?
+    local cmd="$_KWIK_CMD"
+    local start="$_KWIK_CMD_START"
+    unset _KWIK_CMD _KWIK_CMD_START
+    _kwik_prompt_state
+
     # Skip empty
     [ -z "$cmd" ] && return
-    
-    # Skip kwik-cmd itself
+
+    # Skip kwik-cmd itself; the ignore rules of config are applied by
+    # kwik-cmd track (see kwik-cmd ignore)
     [[ "$cmd" == kwik-cmd* ]] && return
-    
-    # Skip ignored commands
-    local base="${cmd%% *}"
-    for ignore in $=KWIk_IGNORE; do
-        [ "$base" = "$ignore" ] && return
-    done
-    
+
+    local -i duration_ms=0
+    [ -n "$start" ] && duration_ms=$(( (EPOCHREALTIME - start) * 1000 ))
+
+    # Suggest a correction right away if the command looks mistyped; only
+    # the statuses kwik-cmd fix can correct are worth the wait
+    case "$exit_code" in
+        1|2|64|127) kwik-cmd fix --hint --exit-code "$exit_code" -- "$cmd" ;;
+    esac
+
     # Track in background
-    kwik-cmd track "$cmd" 2>/dev/null &
+    kwik-cmd track "$cmd" --exit-code $exit_code --duration ${duration_ms}ms \
+        --session "$KWIK_CMD_SESSION" --tty "$TTY" >/dev/null 2>&1 &!
 }
 
+add-zsh-hook preexec _kwik_preexec
+add-zsh-hook precmd _kwik_precmd
+add-zsh-hook zshexit _kwik_exit
+_kwik_prompt_state
+
 # Source this file to enable auto-tracking